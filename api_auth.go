@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// APIMiddleware wraps the handlers of the API server, for example to authenticate requests
+type APIMiddleware func(next http.Handler) http.Handler
+
+// BasicAuth returns a middleware that replies with 401 Unauthorized to requests
+// without the given HTTP basic authentication credentials
+func BasicAuth(username, password string) APIMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			u, p, ok := req.BasicAuth()
+			if !ok || !secureCompare(u, username) || !secureCompare(p, password) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="sidekiq", charset="UTF-8"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// BearerAuth returns a middleware that replies with 401 Unauthorized to requests
+// without an "Authorization: Bearer <token>" header with the given token
+func BearerAuth(token string) APIMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			header := req.Header.Get("Authorization")
+			if !strings.HasPrefix(header, "Bearer ") || !secureCompare(strings.TrimPrefix(header, "Bearer "), token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// secureCompare compares hashes of the strings, so that the time it takes doesn't depend on their content or length
+func secureCompare(given, expected string) bool {
+	g := sha256.Sum256([]byte(given))
+	e := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(g[:], e[:]) == 1
+}
+
+// wrap authenticates the requests of an endpoint and sets its CORS headers
+func (s *APIServer) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next := handler
+		if auth := s.authMiddleware(); auth != nil {
+			next = auth(next)
+		}
+		s.cors(next).ServeHTTP(w, req)
+	})
+}
+
+// authMiddleware returns the middleware authenticating requests, which ConfigureAPIServer
+// can set while the server is running
+func (s *APIServer) authMiddleware() APIMiddleware {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.auth
+}
+
+// isReadOnly reports whether the endpoints that modify data are refused
+func (s *APIServer) isReadOnly() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.readOnly || (s.writesNeedAuth && s.auth == nil)
+}
+
+// writable replies with 403 Forbidden to the requests to an endpoint that modifies
+// data while the server is read-only
+func (s *APIServer) writable(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if s.isReadOnly() {
+			http.Error(w, "the API server is read-only", http.StatusForbidden)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+// cors sets the CORS headers of the requests from an allowed origin, and replies to their
+// preflight requests before they are authenticated, since browsers don't send credentials
+func (s *APIServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := s.allowedOrigin(req.Header.Get("Origin"))
+		if origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// allowedOrigin returns the value of the Access-Control-Allow-Origin header for a
+// request from the given origin, or an empty string when the origin isn't allowed
+func (s *APIServer) allowedOrigin(origin string) string {
+	s.lock.Lock()
+	allowedOrigins := s.allowedOrigins
+	s.lock.Unlock()
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package workers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	handler := BasicAuth("user", "secret")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/stats", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), "Basic")
+
+	request := httptest.NewRequest("GET", "/stats", nil)
+	request.SetBasicAuth("user", "wrong")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request = httptest.NewRequest("GET", "/stats", nil)
+	request.SetBasicAuth("user", "secret")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestBearerAuth(t *testing.T) {
+	handler := BearerAuth("token")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/stats", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+
+	request := httptest.NewRequest("GET", "/stats", nil)
+	request.Header.Set("Authorization", "Bearer wrong")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request = httptest.NewRequest("GET", "/stats", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestAPIServer_Auth(t *testing.T) {
+	s := NewAPIServer(APIOptions{
+		Auth:           BearerAuth("token"),
+		AllowedOrigins: []string{"https://admin.example.com"},
+	})
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/stats", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// Probes call the health endpoints without credentials
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	request := httptest.NewRequest("GET", "/stats", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("Origin", "https://admin.example.com")
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "https://admin.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	// Preflight requests don't have credentials
+	request = httptest.NewRequest("OPTIONS", "/retries/retry", nil)
+	request.Header.Set("Origin", "https://admin.example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, "GET, POST", recorder.Header().Get("Access-Control-Allow-Methods"))
+
+	request = httptest.NewRequest("OPTIONS", "/retries/retry", nil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestAPIServer_CORS(t *testing.T) {
+	request := httptest.NewRequest("GET", "/stats", nil)
+	request.Header.Set("Origin", "https://admin.example.com")
+
+	recorder := httptest.NewRecorder()
+	NewAPIServer(APIOptions{}).Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	recorder = httptest.NewRecorder()
+	NewAPIServer(APIOptions{AllowedOrigins: []string{"*"}}).Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestAPIServer_ReadOnly(t *testing.T) {
+	s := NewAPIServer(APIOptions{ReadOnly: true})
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/retries", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	for _, path := range []string{"/quiet", "/retries/retry_all", "/queues/clear?queue=queue1", "/queues/concurrency?queue=queue1&concurrency=2"} {
+		recorder = httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusForbidden, recorder.Code, path)
+	}
+
+	// Read-only mode applies to endpoints that were already registered
+	s = NewAPIServer(APIOptions{})
+	s.lock.Lock()
+	s.readOnly = true
+	s.lock.Unlock()
+
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("POST", "/quiet", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestConfigureAPIServer(t *testing.T) {
+	saved := globalAPIServer
+	defer func() { globalAPIServer = saved }()
+	globalAPIServer = &APIServer{managers: map[string]*Manager{}, logger: saved.logger, writesNeedAuth: true}
+
+	mux := http.NewServeMux()
+	RegisterAPIEndpoints(mux)
+
+	// Endpoints that modify data are refused until auth is configured
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/quiet", nil))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// Options set after the endpoints were registered apply to the next requests
+	ConfigureAPIServer(APIOptions{Auth: BearerAuth("token"), AllowedOrigins: []string{"https://admin.example.com"}, ReadOnly: true})
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/retries", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request := httptest.NewRequest("GET", "/retries", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("Origin", "https://admin.example.com")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "https://admin.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+
+	// Read-only mode isn't turned off by options that leave it unset
+	ConfigureAPIServer(APIOptions{})
+
+	request = httptest.NewRequest("POST", "/quiet", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// healthCheckTimeout bounds the store checks of the health endpoints
+const healthCheckTimeout = 5 * time.Second
+
+// Healthz replies with 503 Service Unavailable when a manager is unhealthy
+func (s *APIServer) Healthz(w http.ResponseWriter, req *http.Request) {
+	s.health(w, req, false, func(h Health) bool { return h.Healthy })
+}
+
+// Readyz replies with 503 Service Unavailable when a manager isn't ready, or when no manager runs
+func (s *APIServer) Readyz(w http.ResponseWriter, req *http.Request) {
+	s.health(w, req, true, func(h Health) bool { return h.Ready })
+}
+
+func (s *APIServer) health(w http.ResponseWriter, req *http.Request, requireManager bool, ok func(Health) bool) {
+	ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	allHealth := []Health{}
+	for _, m := range s.listManagers() {
+		health := m.CheckHealth(ctx)
+		if !ok(health) {
+			status = http.StatusServiceUnavailable
+		}
+		allHealth = append(allHealth, health)
+	}
+
+	if requireManager && len(allHealth) == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSONStatus(w, status, allHealth)
+}
@@ -0,0 +1,54 @@
+package workers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth_Empty(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	a.Healthz(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "[]\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	a.Readyz(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
+
+func TestHealth(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	mgr, err := newTestManager(testOptionsWithNamespace("prod"))
+	assert.NoError(t, err)
+	mgr.running = true
+	a.AddManager(mgr)
+
+	recorder := httptest.NewRecorder()
+	a.Readyz(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var health []Health
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &health))
+	if assert.Len(t, health, 1) {
+		assert.True(t, health[0].Ready)
+	}
+
+	mgr.quiet = true
+
+	recorder = httptest.NewRecorder()
+	a.Readyz(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.Healthz(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
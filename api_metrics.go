@@ -0,0 +1,26 @@
+package workers
+
+import (
+	"net/http"
+)
+
+// Metrics exposes the metrics of all managers in the Prometheus text format
+func (s *APIServer) Metrics(w http.ResponseWriter, req *http.Request) {
+	registry := newPromRegistry()
+	namespaces := map[string]bool{}
+	for _, m := range s.listManagers() {
+		// Managers sharing a namespace report the same store metrics
+		if !namespaces[m.opts.Namespace] {
+			namespaces[m.opts.Namespace] = true
+			if err := m.collectStoreMetrics(registry); err != nil {
+				s.logger.Error("couldn't retrieve metrics for namespace", "namespace", m.opts.Namespace, LogKeyError, err)
+			}
+		}
+		m.collectMetrics(registry)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := registry.write(w); err != nil {
+		s.logger.Error("couldn't write metrics", LogKeyError, err)
+	}
+}
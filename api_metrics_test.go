@@ -0,0 +1,42 @@
+package workers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Empty(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/metrics", nil)
+	a.Metrics(recorder, request)
+
+	assert.Equal(t, "", recorder.Body.String())
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+}
+
+func TestMetrics_NotEmpty(t *testing.T) {
+	a := APIServer{}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	a.AddManager(&Manager{uuid: "1", opts: opts})
+
+	// A second manager of the same namespace doesn't repeat the store metrics
+	otherOpts := opts
+	otherOpts.ProcessID = "2"
+	a.AddManager(&Manager{uuid: "2", opts: otherOpts})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/metrics", nil)
+	a.Metrics(recorder, request)
+
+	assert.Contains(t, recorder.Body.String(), "# TYPE sidekiq_jobs_processed_total counter\n")
+	assert.Contains(t, recorder.Body.String(), `sidekiq_jobs_processed_total{namespace="prod"} 0`)
+	assert.Equal(t, 1, strings.Count(recorder.Body.String(), "sidekiq_jobs_processed_total{"))
+	assert.Equal(t, 2, len(a.listManagers()))
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"net/http"
+)
+
+func (s *APIServer) Quarantine(w http.ResponseWriter, req *http.Request) {
+	page, pageSizeVal, query, err := parseURLQuery(req)
+	if err != nil {
+		s.logger.Error("couldn't retrieve quarantine filtering query", LogKeyError, err)
+	}
+
+	allMessages := []QuarantinedMessages{}
+	for _, m := range s.listManagers() {
+		messages, err := m.GetQuarantinedMessages(page, pageSizeVal, query)
+		if err != nil {
+			s.logger.Error("couldn't retrieve quarantined messages for manager", LogKeyError, err)
+		} else {
+			allMessages = append(allMessages, messages)
+		}
+	}
+
+	writeJSON(w, allMessages)
+}
+
+func (s *APIServer) DeleteQuarantinedMessage(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	id, ok := requireParam(w, req, "id")
+	if !ok {
+		return
+	}
+
+	var found bool
+	for _, m := range s.listManagers() {
+		deleted, err := m.DeleteQuarantinedMessage(id)
+		if err != nil {
+			s.logger.Error("couldn't delete quarantined message for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		found = found || deleted
+	}
+
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) DeleteAllQuarantinedMessages(w http.ResponseWriter, req *http.Request) {
+	s.bulkJobAction(w, req, (*Manager).DeleteAllQuarantinedMessages)
+}
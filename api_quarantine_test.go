@@ -0,0 +1,78 @@
+package workers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantine_Empty(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/quarantine", nil)
+	a.Quarantine(recorder, request)
+
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
+
+func TestQuarantine_EmptyPage(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	a.AddManager(&Manager{opts: opts})
+
+	recorder := httptest.NewRecorder()
+	a.Quarantine(recorder, httptest.NewRequest("GET", "/quarantine", nil))
+
+	assert.JSONEq(t, `[{"total_quarantined_count":0,"messages":[]}]`, recorder.Body.String())
+}
+
+func TestQuarantine_Actions(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	a.AddManager(mgr)
+
+	quarantineTestMessages(t, opts, "queue1", "not json", "broken")
+
+	recorder := httptest.NewRecorder()
+	a.Quarantine(recorder, httptest.NewRequest("GET", "/quarantine?q=broken", nil))
+	var quarantined []QuarantinedMessages
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &quarantined))
+	assert.Equal(t, int64(1), quarantined[0].TotalQuarantinedCount)
+	assert.Equal(t, "broken", quarantined[0].Messages[0].Payload)
+	id := quarantined[0].Messages[0].ID
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQuarantinedMessage(recorder, httptest.NewRequest("GET", "/quarantine/delete?id="+id, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQuarantinedMessage(recorder, httptest.NewRequest("POST", "/quarantine/delete", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQuarantinedMessage(recorder, httptest.NewRequest("POST", "/quarantine/delete?id="+id, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQuarantinedMessage(recorder, httptest.NewRequest("POST", "/quarantine/delete?id="+id, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteAllQuarantinedMessages(recorder, httptest.NewRequest("POST", "/quarantine/delete_all", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"count":1}`, recorder.Body.String())
+}
@@ -0,0 +1,161 @@
+package workers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+func (s *APIServer) Queues(w http.ResponseWriter, req *http.Request) {
+	allQueues := []Queues{}
+	for _, m := range s.listManagers() {
+		queues, err := m.GetQueues()
+		if err != nil {
+			s.logger.Error("couldn't retrieve queues for manager", LogKeyError, err)
+		} else {
+			allQueues = append(allQueues, Queues{Name: m.opts.ManagerDisplayName, Queues: queues})
+		}
+	}
+
+	writeJSON(w, allQueues)
+}
+
+func (s *APIServer) QueueJobs(w http.ResponseWriter, req *http.Request) {
+	queue, ok := requireParam(w, req, "queue")
+	if !ok {
+		return
+	}
+
+	page, pageSizeVal, query, err := parseURLQuery(req)
+	if err != nil {
+		s.logger.Error("couldn't retrieve queue jobs filtering query", LogKeyError, err)
+	}
+
+	allJobs := []QueueJobs{}
+	for _, m := range s.listManagers() {
+		jobs, err := m.GetQueueJobs(queue, page, pageSizeVal, query)
+		if err != nil {
+			s.logger.Error("couldn't retrieve queue jobs for manager", LogKeyError, err)
+		} else {
+			allJobs = append(allJobs, jobs)
+		}
+	}
+
+	writeJSON(w, allJobs)
+}
+
+func (s *APIServer) DeleteQueueJob(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	queue, ok := requireParam(w, req, "queue")
+	if !ok {
+		return
+	}
+	jid, ok := requireParam(w, req, "jid")
+	if !ok {
+		return
+	}
+
+	var found bool
+	for _, m := range s.listManagers() {
+		deleted, err := m.DeleteQueueJob(queue, jid)
+		if err != nil {
+			s.logger.Error("couldn't delete queue job for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		found = found || deleted
+	}
+
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) ClearQueue(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	queue, ok := requireParam(w, req, "queue")
+	if !ok {
+		return
+	}
+
+	for _, m := range s.listManagers() {
+		if err := m.ClearQueue(queue); err != nil {
+			s.logger.Error("couldn't clear queue for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) DeleteQueue(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	queue, ok := requireParam(w, req, "queue")
+	if !ok {
+		return
+	}
+
+	for _, m := range s.listManagers() {
+		if err := m.DeleteQueue(queue); err != nil {
+			s.logger.Error("couldn't delete queue for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *APIServer) SetQueueConcurrency(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	queue, ok := requireParam(w, req, "queue")
+	if !ok {
+		return
+	}
+	concurrencyParam, ok := requireParam(w, req, "concurrency")
+	if !ok {
+		return
+	}
+	concurrency, err := strconv.Atoi(concurrencyParam)
+	if err != nil || concurrency <= 0 {
+		http.Error(w, "invalid \"concurrency\" parameter", http.StatusBadRequest)
+		return
+	}
+
+	var found bool
+	for _, m := range s.listManagers() {
+		err := m.SetConcurrency(queue, concurrency)
+		if errors.Is(err, ErrWorkerNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("couldn't set concurrency for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		found = true
+	}
+
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Queues contains the queues known to a manager
+type Queues struct {
+	Name   string      `json:"manager_name"`
+	Queues []QueueInfo `json:"queues"`
+}
@@ -0,0 +1,115 @@
+package workers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueues_Empty(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/queues", nil)
+	a.Queues(recorder, request)
+
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
+
+func TestQueues_Actions(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	a.AddManager(mgr)
+	p := &Producer{opts: opts}
+
+	jid, err := p.Enqueue("queue1", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("queue1", "Add", []int{2})
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	a.Queues(recorder, httptest.NewRequest("GET", "/queues", nil))
+	var queues []Queues
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &queues))
+	assert.Len(t, queues, 1)
+	assert.Len(t, queues[0].Queues, 1)
+	assert.Equal(t, int64(2), queues[0].Queues[0].Size)
+
+	recorder = httptest.NewRecorder()
+	a.QueueJobs(recorder, httptest.NewRequest("GET", "/queues/jobs", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.QueueJobs(recorder, httptest.NewRequest("GET", "/queues/jobs?queue=queue1&page_size=1", nil))
+	var jobs []struct {
+		Size int64                    `json:"size"`
+		Jobs []map[string]interface{} `json:"jobs"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &jobs))
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int64(2), jobs[0].Size)
+	assert.Len(t, jobs[0].Jobs, 1)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQueueJob(recorder, httptest.NewRequest("GET", "/queues/jobs/delete?queue=queue1&jid="+jid, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQueueJob(recorder, httptest.NewRequest("POST", "/queues/jobs/delete?queue=queue1&jid="+jid, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQueueJob(recorder, httptest.NewRequest("POST", "/queues/jobs/delete?queue=queue1&jid="+jid, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.ClearQueue(recorder, httptest.NewRequest("POST", "/queues/clear?queue=queue1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	info, err := mgr.GetQueue("queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteQueue(recorder, httptest.NewRequest("POST", "/queues/delete?queue=queue1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	all, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestQueues_SetConcurrency(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	mgr, err := newTestManager(testOptionsWithNamespace("prod"))
+	assert.NoError(t, err)
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+	a.AddManager(mgr)
+
+	recorder := httptest.NewRecorder()
+	a.SetQueueConcurrency(recorder, httptest.NewRequest("GET", "/queues/concurrency?queue=queue1&concurrency=2", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.SetQueueConcurrency(recorder, httptest.NewRequest("POST", "/queues/concurrency?queue=queue1&concurrency=none", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.SetQueueConcurrency(recorder, httptest.NewRequest("POST", "/queues/concurrency?queue=queue2&concurrency=2", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.SetQueueConcurrency(recorder, httptest.NewRequest("POST", "/queues/concurrency?queue=queue1&concurrency=2", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, 2, mgr.concurrency()["queue1"])
+}
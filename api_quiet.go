@@ -0,0 +1,17 @@
+package workers
+
+import (
+	"net/http"
+)
+
+func (s *APIServer) Quiet(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+
+	for _, m := range s.listManagers() {
+		m.Quiet()
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
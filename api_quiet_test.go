@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuiet(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+	a.AddManager(mgr)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+	assert.Eventually(t, func() bool {
+		mgr.lock.Lock()
+		defer mgr.lock.Unlock()
+		return mgr.running
+	}, time.Second, 10*time.Millisecond)
+
+	recorder := httptest.NewRecorder()
+	a.Quiet(recorder, httptest.NewRequest("GET", "/quiet", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.Quiet(recorder, httptest.NewRequest("POST", "/quiet", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.True(t, mgr.IsQuiet())
+
+	mgr.Stop()
+	wg.Wait()
+}
@@ -1,70 +1,123 @@
 package workers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
 )
 
-func (s *apiServer) Retries(w http.ResponseWriter, req *http.Request) {
+func (s *APIServer) Retries(w http.ResponseWriter, req *http.Request) {
 	page, pageSizeVal, query, err := parseURLQuery(req)
 	if err != nil {
-		s.logger.Println("couldn't retrieve retries filtering query:", err)
+		s.logger.Error("couldn't retrieve retries filtering query", LogKeyError, err)
 	}
 
 	allRetries := []Retries{}
-	for _, m := range s.managers {
+	for _, m := range s.listManagers() {
 		r, err := m.GetRetries(page, pageSizeVal, query)
 		if err != nil {
-			s.logger.Println("couldn't retrieve retries for manager:", err)
+			s.logger.Error("couldn't retrieve retries for manager", LogKeyError, err)
 		} else {
 			allRetries = append(allRetries, r)
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJSON(w, allRetries)
+}
+
+func (s *APIServer) RetryNow(w http.ResponseWriter, req *http.Request) {
+	s.jobAction(w, req, (*Manager).RetryNow)
+}
+
+func (s *APIServer) DeleteRetry(w http.ResponseWriter, req *http.Request) {
+	s.jobAction(w, req, (*Manager).DeleteRetry)
+}
+
+func (s *APIServer) KillRetry(w http.ResponseWriter, req *http.Request) {
+	s.jobAction(w, req, (*Manager).KillRetry)
+}
+
+func (s *APIServer) RetryAllRetries(w http.ResponseWriter, req *http.Request) {
+	s.bulkJobAction(w, req, (*Manager).RetryAllRetries)
+}
+
+func (s *APIServer) DeleteAllRetries(w http.ResponseWriter, req *http.Request) {
+	s.bulkJobAction(w, req, (*Manager).DeleteAllRetries)
+}
 
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	enc.Encode(allRetries)
+func (s *APIServer) KillAllRetries(w http.ResponseWriter, req *http.Request) {
+	s.bulkJobAction(w, req, (*Manager).KillAllRetries)
 }
 
-// Retries stores retry information
+func (s *APIServer) DeadJobs(w http.ResponseWriter, req *http.Request) {
+	page, pageSizeVal, query, err := parseURLQuery(req)
+	if err != nil {
+		s.logger.Error("couldn't retrieve dead jobs filtering query", LogKeyError, err)
+	}
+
+	allDeadJobs := []DeadJobs{}
+	for _, m := range s.listManagers() {
+		d, err := m.GetDeadJobs(page, pageSizeVal, query)
+		if err != nil {
+			s.logger.Error("couldn't retrieve dead jobs for manager", LogKeyError, err)
+		} else {
+			allDeadJobs = append(allDeadJobs, d)
+		}
+	}
+
+	writeJSON(w, allDeadJobs)
+}
+
+// Retries stores retry information. When the retries are filtered,
+// TotalRetryCount is the number of matching jobs.
 type Retries struct {
 	TotalRetryCount int64  `json:"total_retry_count"`
 	RetryJobs       []*Msg `json:"retry_jobs"`
 }
 
+// DeadJobs stores the jobs that won't be retried anymore
+type DeadJobs struct {
+	TotalDeadCount int64  `json:"total_dead_count"`
+	DeadJobs       []*Msg `json:"dead_jobs"`
+}
+
+// BulkResult contains the number of jobs affected by a bulk action
+type BulkResult struct {
+	Count int64 `json:"count"`
+}
+
+const defaultPageSize = 10
+
+// parseURLQuery returns the page, page size and match pattern of a request. The
+// pattern matches the jobs containing the q parameter, which is taken literally.
+// Missing or invalid values fall back to the first page of defaultPageSize items.
 func parseURLQuery(req *http.Request) (uint64, int64, string, error) {
-	query := req.URL.Query().Get("q")
+	values := req.URL.Query()
+
+	query := values.Get("q")
 	if len(query) > 0 {
-		query = fmt.Sprintf("*" + query + "*")
-	} else {
-		return 0, 10, query, nil
+		query = "*" + storage.EscapeGlob(query) + "*"
 	}
 
 	var pageVal uint64
-	page := req.URL.Query().Get("page")
-	if len(page) > 0 {
-		pageVal, err := strconv.ParseUint(page, 10, 64)
-		if err != nil {
-			return pageVal, 10, query, nil
+	if page := values.Get("page"); len(page) > 0 {
+		var err error
+		if pageVal, err = strconv.ParseUint(page, 10, 64); err != nil {
+			return 0, defaultPageSize, query, err
 		}
-	} else {
-		return 0, 10, query, nil
 	}
 
-	var pageSizeVal int64
-	pageSize := req.URL.Query().Get("page_size")
-	if len(pageSize) > 0 {
-		pageSizeVal, err := strconv.ParseInt(pageSize, 10, 64)
-		if err != nil {
-			return pageVal, pageSizeVal, query, nil
+	var pageSizeVal int64 = defaultPageSize
+	if pageSize := values.Get("page_size"); len(pageSize) > 0 {
+		var err error
+		if pageSizeVal, err = strconv.ParseInt(pageSize, 10, 64); err != nil || pageSizeVal <= 0 {
+			if err == nil {
+				err = fmt.Errorf("invalid page_size %d", pageSizeVal)
+			}
+			return pageVal, defaultPageSize, query, err
 		}
-	} else {
-		return pageVal, 10, query, nil
 	}
 
 	return pageVal, pageSizeVal, query, nil
@@ -4,16 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRetries_Empty(t *testing.T) {
-	a := apiServer{}
+	a := APIServer{}
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest("GET", "/retries", nil)
@@ -23,8 +24,8 @@ func TestRetries_Empty(t *testing.T) {
 }
 
 func TestRetries_NotEmpty(t *testing.T) {
-	a := &apiServer{
-		logger: log.New(os.Stdout, "go-sidekiq: ", log.Ldate|log.Lmicroseconds),
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
 	}
 
 	// test API replies without registered workers
@@ -41,7 +42,7 @@ func TestRetries_NotEmpty(t *testing.T) {
 	assert.NoError(t, err)
 
 	mgr := &Manager{opts: opts}
-	a.registerManager(mgr)
+	a.AddManager(mgr)
 
 	recorder = httptest.NewRecorder()
 	request = httptest.NewRequest("GET", "/retries", nil)
@@ -51,7 +52,7 @@ func TestRetries_NotEmpty(t *testing.T) {
 	actualReplyParsed := []*Retries{}
 	err = json.Unmarshal(actualWithManagerBytes, &actualReplyParsed)
 	assert.NoError(t, err)
-	assert.Equal(t, []*Retries{{}}, actualReplyParsed)
+	assert.Equal(t, []*Retries{{RetryJobs: []*Msg{}}}, actualReplyParsed)
 
 	//puts messages in retry queue when they fail
 	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
@@ -90,3 +91,85 @@ func TestRetries_NotEmpty(t *testing.T) {
 	assert.NotEqual(t, "[]\n", recorder.Body.String())
 	assert.NotEqual(t, string(actualWithManagerBytes), recorder.Body.String())
 }
+
+func TestParseURLQuery(t *testing.T) {
+	page, pageSize, query, err := parseURLQuery(httptest.NewRequest("GET", "/retries", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), page)
+	assert.Equal(t, int64(10), pageSize)
+	assert.Equal(t, "", query)
+
+	page, pageSize, query, err = parseURLQuery(httptest.NewRequest("GET", "/retries?page=3&page_size=25", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), page)
+	assert.Equal(t, int64(25), pageSize)
+	assert.Equal(t, "", query)
+
+	page, pageSize, query, err = parseURLQuery(httptest.NewRequest("GET", "/retries?q=foo&page=1", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), page)
+	assert.Equal(t, int64(10), pageSize)
+	assert.Equal(t, "*foo*", query)
+
+	// q is taken literally, rather than as a glob pattern
+	_, _, query, err = parseURLQuery(httptest.NewRequest("GET", "/retries?q="+url.QueryEscape(`a*b?[c]\`), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, `*a\*b\?\[c\]\\*`, query)
+
+	_, pageSize, _, err = parseURLQuery(httptest.NewRequest("GET", "/retries?page_size=-1", nil))
+	assert.Error(t, err)
+	assert.Equal(t, int64(10), pageSize)
+}
+
+func TestRetries_Actions(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	a.AddManager(mgr)
+
+	jids := addRetries(t, opts, "Add", "Add", "Subtract", "Multiply")
+
+	recorder := httptest.NewRecorder()
+	a.RetryNow(recorder, httptest.NewRequest("POST", "/retries/retry", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.RetryNow(recorder, httptest.NewRequest("POST", "/retries/retry?jid="+jids[0], nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteRetry(recorder, httptest.NewRequest("POST", "/retries/delete?jid="+jids[0], nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.KillRetry(recorder, httptest.NewRequest("GET", "/retries/kill?jid="+jids[3], nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.KillRetry(recorder, httptest.NewRequest("POST", "/retries/kill?jid="+jids[3], nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeadJobs(recorder, httptest.NewRequest("GET", "/dead", nil))
+	var dead []struct {
+		TotalDeadCount int64 `json:"total_dead_count"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &dead))
+	assert.Equal(t, int64(1), dead[0].TotalDeadCount)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteAllRetries(recorder, httptest.NewRequest("POST", "/retries/delete_all?q=Add", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var result BulkResult
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, int64(1), result.Count)
+
+	recorder = httptest.NewRecorder()
+	a.RetryAllRetries(recorder, httptest.NewRequest("POST", "/retries/retry_all", nil))
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Equal(t, int64(1), result.Count)
+}
@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *APIServer) Scheduled(w http.ResponseWriter, req *http.Request) {
+	page, pageSizeVal, query, err := parseURLQuery(req)
+	if err != nil {
+		s.logger.Error("couldn't retrieve scheduled jobs filtering query", LogKeyError, err)
+	}
+
+	allScheduled := []ScheduledJobs{}
+	for _, m := range s.listManagers() {
+		scheduled, err := m.GetScheduledJobs(page, pageSizeVal, query)
+		if err != nil {
+			s.logger.Error("couldn't retrieve scheduled jobs for manager", LogKeyError, err)
+		} else {
+			allScheduled = append(allScheduled, scheduled)
+		}
+	}
+
+	writeJSON(w, allScheduled)
+}
+
+func (s *APIServer) ScheduledJob(w http.ResponseWriter, req *http.Request) {
+	jid, ok := requireParam(w, req, "jid")
+	if !ok {
+		return
+	}
+
+	for _, m := range s.listManagers() {
+		job, found, err := m.GetScheduledJob(jid)
+		if err != nil {
+			s.logger.Error("couldn't retrieve scheduled job for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found {
+			writeJSON(w, job)
+			return
+		}
+	}
+
+	http.NotFound(w, req)
+}
+
+func (s *APIServer) RescheduleJob(w http.ResponseWriter, req *http.Request) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+
+	atParam, ok := requireParam(w, req, "at")
+	if !ok {
+		return
+	}
+	atSeconds, err := strconv.ParseFloat(atParam, 64)
+	if err != nil {
+		http.Error(w, "invalid \"at\" parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	at := time.Unix(0, int64(atSeconds*NanoSecondPrecision))
+
+	s.jobAction(w, req, func(m *Manager, jid string) (bool, error) {
+		return m.RescheduleJob(jid, at)
+	})
+}
+
+func (s *APIServer) EnqueueScheduledJobNow(w http.ResponseWriter, req *http.Request) {
+	s.jobAction(w, req, (*Manager).EnqueueScheduledJobNow)
+}
+
+func (s *APIServer) DeleteScheduledJob(w http.ResponseWriter, req *http.Request) {
+	s.jobAction(w, req, (*Manager).DeleteScheduledJob)
+}
+
+// ScheduledJobs stores the jobs waiting to be enqueued at a later time.
+// When the jobs are filtered, TotalScheduledCount is the number of matching jobs.
+type ScheduledJobs struct {
+	TotalScheduledCount int64  `json:"total_scheduled_count"`
+	ScheduledJobs       []*Msg `json:"scheduled_jobs"`
+}
@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduled_Empty(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/scheduled", nil)
+	a.Scheduled(recorder, request)
+
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
+
+func TestScheduled_Actions(t *testing.T) {
+	a := &APIServer{
+		logger: NewLogger(slog.LevelInfo, false),
+	}
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	a.AddManager(mgr)
+	p := &Producer{opts: opts}
+
+	jid1, err := p.EnqueueIn("queue1", "Add", 10, []int{1})
+	assert.NoError(t, err)
+	jid2, err := p.EnqueueIn("queue1", "Add", 20, []int{2})
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	a.Scheduled(recorder, httptest.NewRequest("GET", "/scheduled?page_size=1", nil))
+	var scheduled []struct {
+		TotalScheduledCount int64                    `json:"total_scheduled_count"`
+		ScheduledJobs       []map[string]interface{} `json:"scheduled_jobs"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &scheduled))
+	assert.Equal(t, int64(2), scheduled[0].TotalScheduledCount)
+	assert.Len(t, scheduled[0].ScheduledJobs, 1)
+
+	recorder = httptest.NewRecorder()
+	a.ScheduledJob(recorder, httptest.NewRequest("GET", "/scheduled/job?jid="+jid1, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), jid1)
+
+	recorder = httptest.NewRecorder()
+	a.ScheduledJob(recorder, httptest.NewRequest("GET", "/scheduled/job?jid=unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.RescheduleJob(recorder, httptest.NewRequest("POST", "/scheduled/reschedule?jid="+jid1+"&at=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	at := time.Now().Add(time.Hour).Unix()
+	recorder = httptest.NewRecorder()
+	a.RescheduleJob(recorder, httptest.NewRequest("POST", fmt.Sprintf("/scheduled/reschedule?jid=%s&at=%d", jid1, at), nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, float64(at), mustScheduledJob(t, mgr, jid1).Get("at").MustFloat64())
+
+	recorder = httptest.NewRecorder()
+	a.EnqueueScheduledJobNow(recorder, httptest.NewRequest("POST", "/scheduled/enqueue?jid="+jid2, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteScheduledJob(recorder, httptest.NewRequest("POST", "/scheduled/delete?jid="+jid2, nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	a.DeleteScheduledJob(recorder, httptest.NewRequest("POST", "/scheduled/delete?jid="+jid1, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
@@ -2,27 +2,78 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"sort"
 	"sync"
 )
 
-// APIOptions contains the set of configuration options for the global api
+// APIOptions contains the set of configuration options for an API server
 type APIOptions struct {
-	Logger *log.Logger
-	Mux    *http.ServeMux
+	Logger *slog.Logger
+	// Optional mux the endpoints are registered on, a new one by default
+	Mux *http.ServeMux
+
+	// Optional middleware authenticating requests, such as BasicAuth or BearerAuth
+	Auth APIMiddleware
+
+	// Origins allowed to call the endpoints from a browser, or "*" for any origin. Cross-origin
+	// requests aren't allowed by default, except by the global API server, which allows any origin.
+	AllowedOrigins []string
+
+	// Reply with 403 Forbidden to the requests to the endpoints that modify jobs, queues or managers
+	ReadOnly bool
+}
+
+// APIServer serves the stats and the endpoints managing the jobs of a set of managers
+type APIServer struct {
+	lock           sync.Mutex
+	managers       map[string]*Manager
+	logger         *slog.Logger
+	mux            *http.ServeMux
+	auth           APIMiddleware
+	allowedOrigins []string
+	readOnly       bool
+	// Refuse the endpoints that modify data until auth is set, for the global API server
+	writesNeedAuth bool
+}
+
+// NewAPIServer creates an API server for the given managers, with its endpoints
+// registered on the mux returned by Handler
+func NewAPIServer(options APIOptions, managers ...*Manager) *APIServer {
+	s := &APIServer{
+		managers:       map[string]*Manager{},
+		logger:         options.Logger,
+		mux:            options.Mux,
+		auth:           options.Auth,
+		allowedOrigins: options.AllowedOrigins,
+		readOnly:       options.ReadOnly,
+	}
+	if s.logger == nil {
+		s.logger = NewLogger(slog.LevelInfo, false)
+	}
+	if s.mux == nil {
+		s.mux = http.NewServeMux()
+	}
+
+	for _, m := range managers {
+		s.AddManager(m)
+	}
+	s.registerEndpoints(s.mux)
+
+	return s
 }
 
-type apiServer struct {
-	lock     sync.Mutex
-	managers map[string]*Manager
-	logger   *log.Logger
-	mux      *http.ServeMux
+// Handler returns the handler serving the endpoints of the API server, which can be
+// mounted under a prefix with http.StripPrefix
+func (s *APIServer) Handler() http.Handler {
+	return s.mux
 }
 
-func (s *apiServer) registerManager(m *Manager) {
+// AddManager adds a manager to the ones served by the API server
+func (s *APIServer) AddManager(m *Manager) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	if s.managers == nil {
@@ -31,22 +82,94 @@ func (s *apiServer) registerManager(m *Manager) {
 	s.managers[m.uuid] = m
 }
 
-func (s *apiServer) deregisterManager(m *Manager) {
+// RemoveManager removes a manager from the ones served by the API server
+func (s *APIServer) RemoveManager(m *Manager) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	delete(s.managers, m.uuid)
 }
 
+// listManagers returns the managers served by the API server, sorted by display name
+func (s *APIServer) listManagers() []*Manager {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	managers := make([]*Manager, 0, len(s.managers))
+	for _, m := range s.managers {
+		managers = append(managers, m)
+	}
+	sort.Slice(managers, func(i, j int) bool {
+		if managers[i].opts.ManagerDisplayName != managers[j].opts.ManagerDisplayName {
+			return managers[i].opts.ManagerDisplayName < managers[j].opts.ManagerDisplayName
+		}
+		return managers[i].uuid < managers[j].uuid
+	})
+	return managers
+}
+
+func (s *APIServer) registerEndpoints(mux *http.ServeMux) {
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, s.wrap(handler))
+	}
+	write := func(pattern string, handler http.HandlerFunc) {
+		handle(pattern, s.writable(handler))
+	}
+
+	// Health endpoints aren't authenticated, so that liveness and readiness probes don't need credentials
+	mux.HandleFunc("/healthz", s.Healthz)
+	mux.HandleFunc("/readyz", s.Readyz)
+
+	handle("/stats", s.Stats)
+	handle("/stats/history", s.StatsHistory)
+	handle("/metrics", s.Metrics)
+	handle("/retries", s.Retries)
+	handle("/dead", s.DeadJobs)
+	handle("/quarantine", s.Quarantine)
+	handle("/scheduled", s.Scheduled)
+	handle("/scheduled/job", s.ScheduledJob)
+	handle("/queues", s.Queues)
+	handle("/queues/jobs", s.QueueJobs)
+
+	// Endpoints that modify data are refused while the server is read-only
+	write("/quiet", s.Quiet)
+	write("/retries/retry", s.RetryNow)
+	write("/retries/delete", s.DeleteRetry)
+	write("/retries/kill", s.KillRetry)
+	write("/retries/retry_all", s.RetryAllRetries)
+	write("/retries/delete_all", s.DeleteAllRetries)
+	write("/retries/kill_all", s.KillAllRetries)
+	write("/quarantine/delete", s.DeleteQuarantinedMessage)
+	write("/quarantine/delete_all", s.DeleteAllQuarantinedMessages)
+	write("/scheduled/reschedule", s.RescheduleJob)
+	write("/scheduled/enqueue", s.EnqueueScheduledJobNow)
+	write("/scheduled/delete", s.DeleteScheduledJob)
+	write("/queues/jobs/delete", s.DeleteQueueJob)
+	write("/queues/clear", s.ClearQueue)
+	write("/queues/delete", s.DeleteQueue)
+	write("/queues/concurrency", s.SetQueueConcurrency)
+}
+
 var globalHTTPServer *http.Server
 
-var globalAPIServer = &apiServer{
-	managers: map[string]*Manager{},
-	logger:   log.New(os.Stdout, "go-sidekiq: ", log.Ldate|log.Lmicroseconds),
-	mux:      http.NewServeMux(),
+var registerGlobalEndpoints sync.Once
+
+// globalAPIServer serves the running managers, which register themselves
+var globalAPIServer = &APIServer{
+	managers:       map[string]*Manager{},
+	logger:         NewLogger(slog.LevelInfo, false),
+	mux:            http.NewServeMux(),
+	allowedOrigins: []string{"*"},
+	writesNeedAuth: true,
 }
 
-// ConfigureAPIServer allows global API server configuration with the given options
+// ConfigureAPIServer allows global API server configuration with the given options.
+// Only the options that are set are changed, so ReadOnly can't be turned off once set.
+// The endpoints that modify data are refused until Auth is set.
+//
+// Deprecated: use NewAPIServer, which serves the given managers with its own options.
 func ConfigureAPIServer(options APIOptions) {
+	globalAPIServer.lock.Lock()
+	defer globalAPIServer.lock.Unlock()
+
 	if options.Logger != nil {
 		globalAPIServer.logger = options.Logger
 	}
@@ -54,29 +177,137 @@ func ConfigureAPIServer(options APIOptions) {
 	if options.Mux != nil {
 		globalAPIServer.mux = options.Mux
 	}
+
+	if options.Auth != nil {
+		globalAPIServer.auth = options.Auth
+	}
+
+	if options.AllowedOrigins != nil {
+		globalAPIServer.allowedOrigins = options.AllowedOrigins
+	}
+
+	if options.ReadOnly {
+		globalAPIServer.readOnly = true
+	}
 }
 
-// RegisterAPIEndpoints sets up API server endpoints
+// RegisterAPIEndpoints sets up the endpoints of the global API server, which serves the running managers
+//
+// Deprecated: use NewAPIServer and mount its Handler.
 func RegisterAPIEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("/stats", globalAPIServer.Stats)
-	mux.HandleFunc("/retries", globalAPIServer.Retries)
+	globalAPIServer.registerEndpoints(mux)
 }
 
-// StartAPIServer starts the API server
+// StartAPIServer starts the global API server, which serves the running managers
+//
+// Deprecated: use NewAPIServer and serve its Handler with an http.Server.
 func StartAPIServer(port int) {
-	RegisterAPIEndpoints(globalAPIServer.mux)
+	registerGlobalEndpoints.Do(func() {
+		RegisterAPIEndpoints(globalAPIServer.mux)
+	})
 
-	globalAPIServer.logger.Println("APIs are available at", fmt.Sprintf("http://localhost:%v/", port))
+	globalAPIServer.logger.Info("APIs are available", "url", fmt.Sprintf("http://localhost:%v/", port))
 
 	globalHTTPServer = &http.Server{Addr: fmt.Sprint(":", port), Handler: globalAPIServer.mux}
 	if err := globalHTTPServer.ListenAndServe(); err != nil {
-		globalAPIServer.logger.Println(err)
+		globalAPIServer.logger.Error("API server stopped", LogKeyError, err)
 	}
 }
 
-// StopAPIServer stops the API server
+// StopAPIServer stops the global API server
+//
+// Deprecated: use NewAPIServer and shut down the http.Server serving its Handler.
 func StopAPIServer() {
 	if globalHTTPServer != nil {
 		globalHTTPServer.Shutdown(context.Background())
 	}
 }
+
+// jobAction applies a single job action to the job identified by the "jid" parameter
+func (s *APIServer) jobAction(w http.ResponseWriter, req *http.Request, action func(*Manager, string) (bool, error)) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+	jid, ok := requireParam(w, req, "jid")
+	if !ok {
+		return
+	}
+
+	var found bool
+	for _, m := range s.listManagers() {
+		done, err := action(m, jid)
+		if err != nil {
+			s.logger.Error("couldn't update job for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		found = found || done
+	}
+
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkJobAction applies an action to all jobs matching the "q" parameter
+func (s *APIServer) bulkJobAction(w http.ResponseWriter, req *http.Request, action func(*Manager, string) (int64, error)) {
+	if !allowMethod(w, req, http.MethodPost) {
+		return
+	}
+
+	_, _, query, err := parseURLQuery(req)
+	if err != nil {
+		s.logger.Error("couldn't retrieve jobs filtering query", LogKeyError, err)
+	}
+
+	result := BulkResult{}
+	for _, m := range s.listManagers() {
+		count, err := action(m, query)
+		result.Count += count
+		if err != nil {
+			s.logger.Error("couldn't update jobs for manager", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, result)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// allowMethod replies with 405 Method Not Allowed and returns false when the
+// request doesn't use the given method
+func allowMethod(w http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// requireParam replies with 400 Bad Request when the named query parameter is missing
+func requireParam(w http.ResponseWriter, req *http.Request, name string) (string, bool) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		http.Error(w, fmt.Sprintf("missing %q parameter", name), http.StatusBadRequest)
+		return "", false
+	}
+
+	return value, true
+}
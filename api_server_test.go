@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIServer(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.ManagerDisplayName = "manager1"
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	s := NewAPIServer(APIOptions{}, mgr)
+
+	mux := http.NewServeMux()
+	mux.Handle("/sidekiq/", http.StripPrefix("/sidekiq", s.Handler()))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/sidekiq/stats", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var stats []Stats
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "manager1", stats[0].Name)
+	}
+
+	s.RemoveManager(mgr)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/sidekiq/stats", nil))
+	assert.Equal(t, "[]\n", recorder.Body.String())
+}
+
+func TestAPIServer_ConcurrentManagers(t *testing.T) {
+	mgr, err := newTestManager(testOptionsWithNamespace("prod"))
+	assert.NoError(t, err)
+
+	s := NewAPIServer(APIOptions{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			s.AddManager(mgr)
+			s.RemoveManager(mgr)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			recorder := httptest.NewRecorder()
+			s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/queues", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		}
+	}()
+	wg.Wait()
+}
@@ -1,37 +1,65 @@
 package workers
 
 import (
-	"encoding/json"
 	"net/http"
+	"strconv"
 )
 
-func (s *apiServer) Stats(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+const (
+	defaultStatsHistoryDays = 30
+	maxStatsHistoryDays     = 5 * 365
+)
 
+func (s *APIServer) Stats(w http.ResponseWriter, req *http.Request) {
 	allStats := []Stats{}
-	for _, m := range s.managers {
+	for _, m := range s.listManagers() {
 		stats, err := m.GetStats()
 		if err != nil {
-			s.logger.Println("couldn't retrieve stats for manager:", err)
+			s.logger.Error("couldn't retrieve stats for manager", LogKeyError, err)
 		} else {
 			allStats = append(allStats, stats)
 		}
 	}
 
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	enc.Encode(allStats)
+	writeJSON(w, allStats)
+}
+
+func (s *APIServer) StatsHistory(w http.ResponseWriter, req *http.Request) {
+	days := defaultStatsHistoryDays
+	if daysParam := req.URL.Query().Get("days"); len(daysParam) > 0 {
+		var err error
+		if days, err = strconv.Atoi(daysParam); err != nil || days <= 0 {
+			http.Error(w, "invalid \"days\" parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	allHistory := []StatsHistory{}
+	for _, m := range s.listManagers() {
+		history, err := m.GetStatsHistory(days)
+		if err != nil {
+			s.logger.Error("couldn't retrieve stats history for manager", LogKeyError, err)
+		} else {
+			allHistory = append(allHistory, history)
+		}
+	}
+
+	writeJSON(w, allHistory)
 }
 
 // Stats containts current stats for a manager
 type Stats struct {
-	Name       string                 `json:"manager_name"`
-	Processed  int64                  `json:"processed"`
-	Failed     int64                  `json:"failed"`
-	Jobs       map[string][]JobStatus `json:"jobs"`
-	Enqueued   map[string]int64       `json:"enqueued"`
-	RetryCount int64                  `json:"retry_count"`
+	Name             string                 `json:"manager_name"`
+	Quiet            bool                   `json:"quiet"`
+	Processed        int64                  `json:"processed"`
+	Failed           int64                  `json:"failed"`
+	Jobs             map[string][]JobStatus `json:"jobs"`
+	Enqueued         map[string]int64       `json:"enqueued"`
+	PriorityEnqueued map[string]int64       `json:"priority_enqueued"`
+	Concurrency      map[string]int         `json:"concurrency"`
+	RetryCount       int64                  `json:"retry_count"`
+	Quarantined      int64                  `json:"quarantined"`
+	JobMetrics       []JobMetrics           `json:"job_metrics"`
 }
 
 // JobStatus contains the status and data for active jobs of a manager
@@ -39,3 +67,10 @@ type JobStatus struct {
 	Message   *Msg  `json:"message"`
 	StartedAt int64 `json:"started_at"`
 }
+
+// StatsHistory contains the daily processed and failed counters for a manager, keyed by date
+type StatsHistory struct {
+	Name      string           `json:"manager_name"`
+	Processed map[string]int64 `json:"processed"`
+	Failed    map[string]int64 `json:"failed"`
+}
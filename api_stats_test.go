@@ -1,6 +1,8 @@
 package workers
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -8,7 +10,7 @@ import (
 )
 
 func TestStats_Empty(t *testing.T) {
-	a := apiServer{}
+	a := APIServer{}
 
 	recorder := httptest.NewRecorder()
 	request := httptest.NewRequest("GET", "/stats", nil)
@@ -16,3 +18,23 @@ func TestStats_Empty(t *testing.T) {
 
 	assert.Equal(t, "[]\n", recorder.Body.String())
 }
+
+func TestStatsHistory(t *testing.T) {
+	a := APIServer{}
+
+	recorder := httptest.NewRecorder()
+	a.StatsHistory(recorder, httptest.NewRequest("GET", "/stats/history?days=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	a.AddManager(&Manager{opts: opts})
+
+	recorder = httptest.NewRecorder()
+	a.StatsHistory(recorder, httptest.NewRequest("GET", "/stats/history?days=7", nil))
+	var history []StatsHistory
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &history))
+	assert.Len(t, history, 1)
+	assert.Len(t, history[0].Processed, 7)
+	assert.Len(t, history[0].Failed, 7)
+}
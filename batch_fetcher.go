@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// batchFetcher moves up to batchSize messages at once to the in progress list, and
+// buffers them for the runners that are ready. Buffered messages that weren't sent
+// to a runner are moved back to the queue when the fetcher is closed.
+type batchFetcher struct {
+	*simpleFetcher
+
+	batchSize int
+
+	bufferLock sync.Mutex
+	buffer     []string
+}
+
+func newBatchFetcher(queue string, opts Options) *batchFetcher {
+	return &batchFetcher{
+		simpleFetcher: newSimpleFetcher(queue, opts),
+		batchSize:     opts.FetchBatchSize,
+	}
+}
+
+func (f *batchFetcher) Fetch() {
+	f.processOldMessages()
+
+	go func() {
+		for {
+			// f.Close() has been called
+			if f.Closed() {
+				break
+			}
+			<-f.Ready()
+			// Don't fetch a job when the fetcher was closed while waiting for a ready runner
+			if f.Closed() {
+				break
+			}
+			f.tryFetchMessage()
+		}
+	}()
+
+	<-f.stop
+	// Stop the polling goroutine
+	close(f.closed)
+	f.requeue(f.takeBuffer()...)
+	// Signal to Close() that the fetcher has stopped
+	close(f.exit)
+}
+
+// tryFetchMessage sends the next buffered message to a runner, fetching a batch
+// of messages when the buffer is empty
+func (f *batchFetcher) tryFetchMessage() {
+	message, ok := f.nextMessage()
+	if !ok {
+		f.startFetch()
+		messages, err := f.store.DequeueMessages(context.Background(), f.queue, f.inprogressQueue(), f.batchSize, 1*time.Second)
+		f.endFetch()
+		if err != nil {
+			if err != storage.NoMessage {
+				f.logger.Error("couldn't fetch message", LogKeyQueue, f.queue, LogKeyError, err)
+				f.reportError(err)
+			}
+			return
+		}
+
+		if !f.fillBuffer(messages) {
+			// The fetcher was closed while fetching
+			f.requeue(messages...)
+			return
+		}
+		message, _ = f.nextMessage()
+	}
+
+	msg := f.parseMessage(message)
+	if msg == nil {
+		return
+	}
+
+	f.events.publish(EventFetched, f.queue, msg, nil)
+
+	select {
+	case f.Messages() <- msg:
+	case <-f.closed:
+		// No runner is left to process the message
+		f.requeue(message)
+	}
+}
+
+func (f *batchFetcher) nextMessage() (string, bool) {
+	f.bufferLock.Lock()
+	defer f.bufferLock.Unlock()
+	if len(f.buffer) == 0 {
+		return "", false
+	}
+	message := f.buffer[0]
+	f.buffer = f.buffer[1:]
+	return message, true
+}
+
+// fillBuffer buffers fetched messages, unless the fetcher was closed and the buffer already requeued
+func (f *batchFetcher) fillBuffer(messages []string) bool {
+	f.bufferLock.Lock()
+	defer f.bufferLock.Unlock()
+	if f.Closed() {
+		return false
+	}
+	f.buffer = append(f.buffer, messages...)
+	return true
+}
+
+func (f *batchFetcher) takeBuffer() []string {
+	f.bufferLock.Lock()
+	defer f.bufferLock.Unlock()
+	messages := f.buffer
+	f.buffer = nil
+	return messages
+}
+
+// requeue moves messages that weren't sent to a runner back to the queue
+func (f *batchFetcher) requeue(messages ...string) {
+	if err := f.store.RequeueMessages(context.Background(), f.inprogressQueue(), f.queue, messages); err != nil {
+		// They'll be fetched again from the in progress list when the manager restarts
+		f.logger.Error("couldn't requeue messages", LogKeyQueue, f.queue, LogKeyError, err)
+		f.reportError(err)
+	}
+}
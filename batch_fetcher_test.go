@@ -0,0 +1,179 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildBatchFetch(queue string, batchSize int, opts Options) *batchFetcher {
+	opts.FetchBatchSize = batchSize
+	fetch := newBatchFetcher(queue, opts)
+	go fetch.Fetch()
+	return fetch
+}
+
+func TestBatchFetch(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+
+	var messages []*Msg
+	for i := 0; i < 3; i++ {
+		message, _ := NewMsg(fmt.Sprintf("{\"foo\":\"bar%d\"}", i))
+		opts.client.LPush(ctx, "queue:batchQueue1", message.ToJson())
+		messages = append(messages, message)
+	}
+
+	fetch := buildBatchFetch("batchQueue1", 2, opts)
+
+	fetch.Ready() <- true
+	assert.Equal(t, messages[0], <-fetch.Messages())
+
+	// The whole batch was moved to the in progress list, and the rest of it is buffered
+	len, err := opts.client.LLen(ctx, "queue:batchQueue1:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), len)
+
+	len, err = opts.client.LLen(ctx, "queue:batchQueue1").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), len)
+
+	fetch.Ready() <- true
+	assert.Equal(t, messages[1], <-fetch.Messages())
+	fetch.Ready() <- true
+	assert.Equal(t, messages[2], <-fetch.Messages())
+
+	for _, message := range messages {
+		fetch.Acknowledge(message)
+	}
+
+	len, err = opts.client.LLen(ctx, "queue:batchQueue1:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), len)
+
+	fetch.Close()
+}
+
+func TestBatchFetch_RequeuesBufferOnClose(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+
+	var messages []string
+	for i := 0; i < 4; i++ {
+		message := fmt.Sprintf("{\"foo\":\"bar%d\"}", i)
+		opts.client.LPush(ctx, "queue:batchQueue2", message)
+		messages = append(messages, message)
+	}
+
+	fetch := buildBatchFetch("batchQueue2", 10, opts)
+
+	fetch.Ready() <- true
+	message := <-fetch.Messages()
+	fetch.Close()
+
+	// The message sent to a runner stays in progress until it's acknowledged
+	inprogress, err := opts.client.LRange(ctx, "queue:batchQueue2:1:inprogress", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{message.OriginalJson()}, inprogress)
+
+	queued, err := opts.client.LRange(ctx, "queue:batchQueue2", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{messages[3], messages[2], messages[1]}, queued)
+
+	fetch.Acknowledge(message)
+}
+
+func TestManager_BatchFetch(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.FetchBatchSize = 5
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	processed := map[string]bool{}
+	done := make(chan bool)
+	mgr.AddWorker("queue1", 2, func(m *Msg) error {
+		lock.Lock()
+		defer lock.Unlock()
+		processed[m.Jid()] = true
+		if len(processed) == 12 {
+			close(done)
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	for i := 0; i < 12; i++ {
+		_, err = mgr.Producer().Enqueue("queue1", "any", []int{i})
+		assert.NoError(t, err)
+	}
+	<-done
+
+	mgr.Stop()
+	wg.Wait()
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), stats.Processed)
+
+	size, err := mgr.opts.store.QueueSize(context.Background(), "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func benchmarkFetch(b *testing.B, newFetcher func(opts Options) Fetcher) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		opts.store.EnqueueMessageNow(ctx, "benchQueue", fmt.Sprintf("{\"jid\":\"%d\",\"args\":[]}", i))
+	}
+
+	fetch := newFetcher(opts)
+	go fetch.Fetch()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fetch.Ready() <- true
+		fetch.Acknowledge(<-fetch.Messages())
+	}
+	b.StopTimer()
+
+	fetch.Close()
+}
+
+func BenchmarkSimpleFetch(b *testing.B) {
+	benchmarkFetch(b, func(opts Options) Fetcher {
+		return newSimpleFetcher("benchQueue", opts)
+	})
+}
+
+func BenchmarkBatchFetch(b *testing.B) {
+	for _, size := range []int{10, 100} {
+		b.Run(fmt.Sprintf("BatchSize%d", size), func(b *testing.B) {
+			benchmarkFetch(b, func(opts Options) Fetcher {
+				opts.FetchBatchSize = size
+				return newBatchFetcher("benchQueue", opts)
+			})
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// crossSlotHook stands in for the CROSSSLOT checks of Redis Cluster: it fails
+// commands using keys of the namespace that aren't inside its hash tag, since
+// those would be hashed to other slots.
+type crossSlotHook struct {
+	untagged string
+
+	lock  sync.Mutex
+	found []string
+}
+
+func (h *crossSlotHook) check(cmd redis.Cmder) error {
+	for _, arg := range cmd.Args()[1:] {
+		if key, ok := arg.(string); ok && strings.HasPrefix(key, h.untagged) {
+			h.lock.Lock()
+			h.found = append(h.found, fmt.Sprint(cmd.Args()))
+			h.lock.Unlock()
+			return fmt.Errorf("CROSSSLOT key %s isn't in the namespace hash tag", key)
+		}
+	}
+	return nil
+}
+
+func (h *crossSlotHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.check(cmd)
+}
+
+func (h *crossSlotHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *crossSlotHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	for _, cmd := range cmds {
+		if err := h.check(cmd); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+func (h *crossSlotHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// newTestClusterClient returns a cluster client serving all slots from the test Redis server
+func newTestClusterClient() *redis.ClusterClient {
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{{
+				Start: 0,
+				End:   16383,
+				Nodes: []redis.ClusterNode{{Addr: "localhost:6379"}},
+			}}, nil
+		},
+		NewClient: func(opt *redis.Options) *redis.Client {
+			opt.DB = 15
+			return redis.NewClient(opt)
+		},
+	})
+}
+
+func TestManager_RedisCluster(t *testing.T) {
+	ctx := context.Background()
+
+	client := newTestClusterClient()
+	hook := &crossSlotHook{untagged: "cluster:"}
+	client.AddHook(hook)
+
+	mgr, err := NewManagerWithRedisClient(Options{
+		ProcessID:    "1",
+		Namespace:    "cluster",
+		PollInterval: time.Second,
+	}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, "{cluster}:", mgr.opts.Namespace)
+	assert.Nil(t, mgr.GetRedisClient())
+	assert.Equal(t, client, mgr.GetRedisUniversalClient())
+	assert.NoError(t, client.FlushDB(ctx).Err())
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	prod := mgr.Producer()
+	_, err = prod.Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+	cc.ackSyncCh <- true
+
+	_, err = prod.EnqueueIn("queue1", "any", 1, cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+	cc.ackSyncCh <- true
+
+	mgr.Stop()
+	wg.Wait()
+
+	quarantineTestMessages(t, mgr.opts, "queue1", "not json")
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Processed)
+	assert.Equal(t, int64(1), stats.Quarantined)
+
+	queues, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Len(t, queues, 1)
+
+	keys, err := client.Keys(ctx, "*").Result()
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.True(t, strings.HasPrefix(key, "{cluster}:"), key)
+	}
+	assert.Empty(t, hook.found)
+}
@@ -0,0 +1,173 @@
+package workers
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrWorkerNotFound is returned when a manager has no worker for a queue
+var ErrWorkerNotFound = errors.New("no worker for queue")
+
+// ConcurrencyState contains the state of a worker given to its ConcurrencyPolicy
+type ConcurrencyState struct {
+	Queue       string
+	Concurrency int
+	// Number of runners processing a job
+	Busy int
+	// Number of jobs waiting in the queue, including the jobs enqueued with a priority
+	Size int64
+	// Time the oldest job of the queue has been waiting
+	Latency time.Duration
+}
+
+// ConcurrencyPolicy returns the concurrency a worker should have given its state. It is
+// evaluated every Options.ConcurrencyPolicyInterval while the manager runs, and can combine
+// the state with other signals, such as the CPU usage of the process. Values lower than 1
+// leave the concurrency unchanged.
+type ConcurrencyPolicy func(state ConcurrencyState) int
+
+// LatencyConcurrencyPolicy adds a runner when the oldest job of the queue has been waiting
+// longer than maxLatency, and drains one when the queue is empty and a runner is idle,
+// keeping the concurrency between min and max
+func LatencyConcurrencyPolicy(min, max int, maxLatency time.Duration) ConcurrencyPolicy {
+	return func(state ConcurrencyState) int {
+		concurrency := state.Concurrency
+		if state.Latency > maxLatency {
+			concurrency++
+		} else if state.Size == 0 && state.Busy < state.Concurrency {
+			concurrency--
+		}
+
+		if concurrency > max {
+			concurrency = max
+		}
+		if concurrency < min {
+			concurrency = min
+		}
+		return concurrency
+	}
+}
+
+// SetConcurrency changes the number of jobs processed at once by the workers of a queue.
+// Runners are added right away when the manager runs, and removed runners finish the job
+// they are processing first.
+func (m *Manager) SetConcurrency(queue string, concurrency int) error {
+	if concurrency <= 0 {
+		return errors.New("concurrency must be greater than 0")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	found := false
+	for _, w := range m.workers {
+		if w.queue == queue {
+			w.setConcurrency(concurrency)
+			found = true
+		}
+	}
+	if !found {
+		return ErrWorkerNotFound
+	}
+	return nil
+}
+
+// SetConcurrencyPolicy sets the policy that automatically changes the concurrency of the
+// workers of a queue while the manager runs, or removes it when policy is nil
+func (m *Manager) SetConcurrencyPolicy(queue string, policy ConcurrencyPolicy) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	found := false
+	for _, w := range m.workers {
+		if w.queue == queue {
+			w.policy = policy
+			found = true
+		}
+	}
+	if !found {
+		return ErrWorkerNotFound
+	}
+	return nil
+}
+
+// concurrency returns the current concurrency of the workers of each queue
+func (m *Manager) concurrency() map[string]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	res := map[string]int{}
+	for _, w := range m.workers {
+		res[w.queue] += w.getConcurrency()
+	}
+	return res
+}
+
+// concurrencyScaler periodically applies the concurrency policies of the workers while the manager runs
+type concurrencyScaler struct {
+	manager  *Manager
+	interval time.Duration
+	logger   *slog.Logger
+	done     chan bool
+}
+
+func newConcurrencyScaler(m *Manager) *concurrencyScaler {
+	return &concurrencyScaler{
+		manager:  m,
+		interval: m.opts.ConcurrencyPolicyInterval,
+		logger:   m.logger,
+		done:     make(chan bool),
+	}
+}
+
+func (s *concurrencyScaler) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.scale()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *concurrencyScaler) scale() {
+	s.manager.lock.Lock()
+	var workers []*worker
+	var policies []ConcurrencyPolicy
+	for _, w := range s.manager.workers {
+		if w.policy != nil {
+			workers = append(workers, w)
+			policies = append(policies, w.policy)
+		}
+	}
+	s.manager.lock.Unlock()
+
+	for i, w := range workers {
+		info, err := s.manager.GetQueue(w.queue)
+		if err != nil {
+			s.logger.Error("couldn't retrieve queue for concurrency policy", LogKeyQueue, w.queue, LogKeyError, err)
+			continue
+		}
+
+		state := ConcurrencyState{
+			Queue:       w.queue,
+			Concurrency: w.getConcurrency(),
+			Busy:        len(w.inProgressMessages()),
+			Size:        info.Size + info.PrioritySize,
+			Latency:     time.Duration(info.Latency * float64(time.Second)),
+		}
+		concurrency := policies[i](state)
+		if concurrency > 0 && concurrency != state.Concurrency {
+			s.logger.Info("changing concurrency", LogKeyQueue, w.queue, "from", state.Concurrency, "to", concurrency)
+			w.setConcurrency(concurrency)
+		}
+	}
+}
+
+func (s *concurrencyScaler) quit() {
+	close(s.done)
+}
@@ -0,0 +1,143 @@
+package workers
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_SetConcurrency(t *testing.T) {
+	testLogger := NewLogger(slog.LevelDebug, false)
+
+	readyCh := make(chan bool)
+	msgCh := make(chan *Msg)
+	ackCh := make(chan *Msg)
+	closeCh := make(chan bool)
+
+	df := dummyFetcher{
+		queue:       func() string { return "q" },
+		fetch:       func() { <-closeCh },
+		acknowledge: func(m *Msg) { ackCh <- m },
+		ready:       func() chan bool { return readyCh },
+		messages:    func() chan *Msg { return msgCh },
+		close:       func() { close(closeCh) },
+		closed: func() bool {
+			select {
+			case <-closeCh:
+				return true
+			default:
+				return false
+			}
+		},
+	}
+
+	cc := newCallCounter()
+	w := newWorker(testLogger, "q", 1, cc.F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		w.start(df)
+		wg.Done()
+	}()
+
+	// Three jobs are processed at once once runners were added
+	w.setConcurrency(3)
+	for i := 0; i < 3; i++ {
+		msgCh <- cc.syncMsg()
+		<-cc.syncCh
+	}
+	assert.Len(t, w.inProgressMessages(), 3)
+
+	// Drained runners finish their job first
+	w.setConcurrency(1)
+	assert.Equal(t, 1, w.getConcurrency())
+	assert.Len(t, w.inProgressMessages(), 3)
+
+	for i := 0; i < 3; i++ {
+		cc.ackSyncCh <- true
+		assert.True(t, (<-ackCh).ack)
+	}
+	assert.Eventually(t, func() bool {
+		w.runnersLock.Lock()
+		defer w.runnersLock.Unlock()
+		return len(w.runners) == 1 && len(w.draining) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	msgCh <- cc.msg()
+	<-ackCh
+	assert.Equal(t, 4, cc.count)
+
+	w.quit()
+	wg.Wait()
+}
+
+func TestLatencyConcurrencyPolicy(t *testing.T) {
+	policy := LatencyConcurrencyPolicy(2, 4, time.Second)
+
+	assert.Equal(t, 4, policy(ConcurrencyState{Concurrency: 3, Busy: 3, Size: 10, Latency: 2 * time.Second}))
+	assert.Equal(t, 4, policy(ConcurrencyState{Concurrency: 4, Busy: 4, Size: 10, Latency: 2 * time.Second}))
+	assert.Equal(t, 3, policy(ConcurrencyState{Concurrency: 3, Busy: 3, Size: 10, Latency: time.Millisecond}))
+	assert.Equal(t, 2, policy(ConcurrencyState{Concurrency: 3, Busy: 1}))
+	assert.Equal(t, 2, policy(ConcurrencyState{Concurrency: 2}))
+	assert.Equal(t, 2, policy(ConcurrencyState{Concurrency: 1, Busy: 1}))
+}
+
+func TestManager_SetConcurrency(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorker("queue1", 2, newCallCounter().F)
+
+	assert.ErrorIs(t, mgr.SetConcurrency("queue2", 1), ErrWorkerNotFound)
+	assert.Error(t, mgr.SetConcurrency("queue1", 0))
+	assert.NoError(t, mgr.SetConcurrency("queue1", 4))
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{mgr.opts.Namespace + "queue1": 4}, stats.Concurrency)
+}
+
+func TestManager_SetConcurrencyPolicy(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.ConcurrencyPolicyInterval = 10 * time.Millisecond
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	var lock sync.Mutex
+	var states []ConcurrencyState
+	assert.ErrorIs(t, mgr.SetConcurrencyPolicy("queue2", nil), ErrWorkerNotFound)
+	assert.NoError(t, mgr.SetConcurrencyPolicy("queue1", func(state ConcurrencyState) int {
+		lock.Lock()
+		defer lock.Unlock()
+		states = append(states, state)
+		return 3
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	assert.Eventually(t, func() bool {
+		return mgr.concurrency()["queue1"] == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mgr.Stop()
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.NotEmpty(t, states) {
+		assert.Equal(t, ConcurrencyState{Queue: "queue1", Concurrency: 1}, states[0])
+	}
+}
@@ -56,7 +56,7 @@ func (c *Cron) enqueueEntries(ctx context.Context, entries []*cronEntry, forTime
 			if entry.ShouldEnqueue(forTime) {
 				score := float64(forTime.Unix())
 				member := entry.Next(forTime).Format(LastEnqueueTimeFormat)
-				r := c.mgr.GetRedisClient().ZAdd(context.Background(), entry.EnqueuedKey(), &redis.Z{Score: score, Member: member})
+				r := c.mgr.GetRedisUniversalClient().ZAdd(context.Background(), entry.EnqueuedKey(), &redis.Z{Score: score, Member: member})
 				if r.Val() == 1 {
 					c.Enqueue(ctx, entry, forTime)
 					c.log.Printf("enqueued job for cron %s, at %s", entry.Name, member)
@@ -78,10 +78,10 @@ func (c *Cron) Enqueue(ctx context.Context, entry *cronEntry, forTime time.Time)
 		return false
 	}
 
-	client := c.mgr.GetRedisClient()
+	client := c.mgr.GetRedisUniversalClient()
 
 	enqueuedAt := forTime.Format(LastEnqueueTimeFormat)
-	c.mgr.GetRedisClient().HSet(ctx, entry.CronJobKey(), "last_enqueued_at", enqueuedAt)
+	c.mgr.GetRedisUniversalClient().HSet(ctx, entry.CronJobKey(), "last_enqueued_at", enqueuedAt)
 	hist, _ := json.Marshal(map[string]string{"jid": jid, "enqueued": enqueuedAt})
 	client.LPush(ctx, entry.JIDHistoryKey(), hist)
 
@@ -90,7 +90,7 @@ func (c *Cron) Enqueue(ctx context.Context, entry *cronEntry, forTime time.Time)
 
 // CronEntries returns all of the entries associated with the sidekiq instance.
 func (c *Cron) CronEntries(ctx context.Context) ([]*cronEntry, error) {
-	redis := c.mgr.GetRedisClient()
+	redis := c.mgr.GetRedisUniversalClient()
 	cmd := redis.SMembers(ctx, "cron_jobs")
 	if cmd.Err() != nil {
 		return nil, cmd.Err()
@@ -98,7 +98,7 @@ func (c *Cron) CronEntries(ctx context.Context) ([]*cronEntry, error) {
 
 	var entries []*cronEntry
 	for _, val := range cmd.Val() {
-		r := c.mgr.GetRedisClient().HGetAll(ctx, val)
+		r := c.mgr.GetRedisUniversalClient().HGetAll(ctx, val)
 		if r.Err() != nil {
 			return nil, cmd.Err()
 		}
@@ -110,12 +110,12 @@ func (c *Cron) CronEntries(ctx context.Context) ([]*cronEntry, error) {
 
 // AddCron adds a cron entry to sidekiq. If the cron entry already exists, its state will be updated.
 func (c *Cron) AddCron(ctx context.Context, e *cronEntry) error {
-	cmd := c.mgr.GetRedisClient().SAdd(ctx, "cron_jobs", e.CronJobKey())
+	cmd := c.mgr.GetRedisUniversalClient().SAdd(ctx, "cron_jobs", e.CronJobKey())
 	if cmd.Err() != nil {
 		return cmd.Err()
 	}
 
-	c.mgr.GetRedisClient().HSet(ctx, e.CronJobKey(), e.ToMap())
+	c.mgr.GetRedisUniversalClient().HSet(ctx, e.CronJobKey(), e.ToMap())
 	return nil
 }
 
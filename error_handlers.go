@@ -0,0 +1,115 @@
+package workers
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ErrorSource tells where an error reported to error handlers happened
+type ErrorSource string
+
+const (
+	// ErrorSourceJob is a job returning an error or panicking
+	ErrorSourceJob ErrorSource = "job"
+	// ErrorSourceFetch is a fetcher failing to read from the store
+	ErrorSourceFetch ErrorSource = "fetch"
+	// ErrorSourceMessage is a fetched payload that couldn't be parsed
+	ErrorSourceMessage ErrorSource = "message"
+	// ErrorSourceScheduler is the scheduler failing to enqueue scheduled or retried jobs
+	ErrorSourceScheduler ErrorSource = "scheduler"
+)
+
+// ErrorContext describes the circumstances of an error reported to error handlers
+type ErrorContext struct {
+	Source ErrorSource
+	// Queue is empty for scheduler errors
+	Queue string
+	// Message is nil when the error isn't about a job or the payload couldn't be parsed
+	Message *Msg
+	// RawMessage is the payload of the job, when there is one
+	RawMessage string
+	// Stack is the stack trace of the goroutine where the error was caught
+	Stack []byte
+}
+
+// ErrorHandler is notified of job failures and of the errors the manager runs into, for
+// example to send them to an error tracker. Handlers are called synchronously, so slow
+// handlers delay job processing.
+type ErrorHandler interface {
+	HandleError(err error, ctx ErrorContext)
+}
+
+// ErrorHandlerFunc adapts a function into an ErrorHandler
+type ErrorHandlerFunc func(err error, ctx ErrorContext)
+
+// HandleError calls f(err, ctx)
+func (f ErrorHandlerFunc) HandleError(err error, ctx ErrorContext) {
+	f(err, ctx)
+}
+
+// SetErrorHandlers sets the error handlers that will be sequentially executed for every error.
+func (m *Manager) SetErrorHandlers(handlers ...ErrorHandler) {
+	m.errorHandlersLock.Lock()
+	defer m.errorHandlersLock.Unlock()
+	m.errorHandlers = handlers
+}
+
+// AddErrorHandlers adds error handlers to be executed for every error.
+func (m *Manager) AddErrorHandlers(handlers ...ErrorHandler) {
+	m.errorHandlersLock.Lock()
+	defer m.errorHandlersLock.Unlock()
+	m.errorHandlers = append(m.errorHandlers, handlers...)
+}
+
+// reportError calls the error handlers, adding the current stack trace when the context has none
+func (m *Manager) reportError(err error, ctx ErrorContext) {
+	m.errorHandlersLock.RLock()
+	handlers := m.errorHandlers
+	m.errorHandlersLock.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	if ctx.Stack == nil {
+		ctx.Stack = callStack()
+	}
+	if ctx.RawMessage == "" && ctx.Message != nil {
+		ctx.RawMessage = ctx.Message.OriginalJson()
+	}
+
+	for _, h := range handlers {
+		h.HandleError(err, ctx)
+	}
+}
+
+// callStack returns the stack trace of the calling goroutine
+func callStack() []byte {
+	buf := make([]byte, 4096)
+	return buf[:runtime.Stack(buf, false)]
+}
+
+// errorsJob wraps a job to report its failures to the error handlers
+func (m *Manager) errorsJob(queue string, job JobFunc) JobFunc {
+	return func(message *Msg) (err error) {
+		defer func() {
+			if e := recover(); e != nil {
+				var ok bool
+				if err, ok = e.(error); !ok {
+					err = fmt.Errorf("%v", e)
+				}
+
+				// The stack still contains the frames that panicked
+				m.reportError(err, ErrorContext{Source: ErrorSourceJob, Queue: queue, Message: message, Stack: callStack()})
+				panic(e)
+			}
+		}()
+
+		err = job(message)
+		if err != nil {
+			m.reportError(err, ErrorContext{Source: ErrorSourceJob, Queue: queue, Message: message})
+		}
+
+		return
+	}
+}
@@ -0,0 +1,140 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedError struct {
+	err error
+	ctx ErrorContext
+}
+
+type errorRecorder struct {
+	lock   sync.Mutex
+	errors []recordedError
+}
+
+func (r *errorRecorder) HandleError(err error, ctx ErrorContext) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errors = append(r.errors, recordedError{err: err, ctx: ctx})
+}
+
+func (r *errorRecorder) recorded() []recordedError {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]recordedError(nil), r.errors...)
+}
+
+func TestManager_ErrorHandlers(t *testing.T) {
+	mgr := &Manager{}
+	recorder := &errorRecorder{}
+	var funcCalls int
+	mgr.SetErrorHandlers(recorder)
+	mgr.AddErrorHandlers(ErrorHandlerFunc(func(err error, ctx ErrorContext) {
+		funcCalls++
+	}))
+
+	message, _ := NewMsg(`{"jid":"1","class":"Add"}`)
+
+	t.Run("job errors", func(t *testing.T) {
+		recorder.errors = nil
+		assert.NoError(t, mgr.errorsJob("q", func(m *Msg) error { return nil })(message))
+		assert.Empty(t, recorder.recorded())
+
+		err := mgr.errorsJob("q", func(m *Msg) error { return errors.New("boom") })(message)
+		assert.EqualError(t, err, "boom")
+
+		recorded := recorder.recorded()
+		if assert.Len(t, recorded, 1) {
+			assert.EqualError(t, recorded[0].err, "boom")
+			assert.Equal(t, ErrorSourceJob, recorded[0].ctx.Source)
+			assert.Equal(t, "q", recorded[0].ctx.Queue)
+			assert.Equal(t, message, recorded[0].ctx.Message)
+			assert.Equal(t, message.OriginalJson(), recorded[0].ctx.RawMessage)
+			assert.NotEmpty(t, recorded[0].ctx.Stack)
+		}
+		assert.Equal(t, 1, funcCalls)
+	})
+
+	t.Run("job panics", func(t *testing.T) {
+		recorder.errors = nil
+		assert.Panics(t, func() {
+			mgr.errorsJob("q", func(m *Msg) error { panic("oops") })(message)
+		})
+
+		recorded := recorder.recorded()
+		if assert.Len(t, recorded, 1) {
+			assert.EqualError(t, recorded[0].err, "oops")
+			assert.Equal(t, ErrorSourceJob, recorded[0].ctx.Source)
+			assert.Contains(t, string(recorded[0].ctx.Stack), "panic")
+		}
+	})
+
+	t.Run("fetch errors", func(t *testing.T) {
+		recorder.errors = nil
+		mgr.fetchError("q", errors.New("connection refused"))
+
+		recorded := recorder.recorded()
+		if assert.Len(t, recorded, 1) {
+			assert.Equal(t, ErrorSourceFetch, recorded[0].ctx.Source)
+			assert.Equal(t, "q", recorded[0].ctx.Queue)
+			assert.Nil(t, recorded[0].ctx.Message)
+		}
+	})
+}
+
+func TestFetcher_InvalidMessageReported(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	mgr := &Manager{opts: opts}
+	recorder := &errorRecorder{}
+	mgr.AddErrorHandlers(recorder)
+
+	f := mgr.newFetcher("q").(*simpleFetcher)
+	f.sendMessage("not json", EventFetched)
+
+	recorded := recorder.recorded()
+	if assert.Len(t, recorded, 1) {
+		assert.Error(t, recorded[0].err)
+		assert.Equal(t, ErrorSourceMessage, recorded[0].ctx.Source)
+		assert.Equal(t, "q", recorded[0].ctx.Queue)
+		assert.Equal(t, "not json", recorded[0].ctx.RawMessage)
+	}
+}
+
+func TestScheduled_ErrorsReported(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	mgr := &Manager{opts: opts}
+	recorder := &errorRecorder{}
+	mgr.AddErrorHandlers(recorder)
+
+	scheduled := newScheduledWorker(opts)
+	scheduled.onError = mgr.schedulerError
+
+	// An empty set isn't an error
+	scheduled.poll()
+	assert.Empty(t, recorder.recorded())
+
+	now := nowToSecondsWithNanoPrecision()
+	opts.client.ZAdd(ctx, retryQueue(opts.Namespace), &redis.Z{Score: now - 10.0, Member: "not json"})
+
+	scheduled.poll()
+
+	recorded := recorder.recorded()
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, ErrorSourceScheduler, recorded[0].ctx.Source)
+		assert.Equal(t, "not json", recorded[0].ctx.RawMessage)
+	}
+}
@@ -0,0 +1,200 @@
+package workers
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies a job lifecycle event
+type EventType string
+
+const (
+	// EventEnqueued is emitted when a producer enqueues or schedules a job
+	EventEnqueued EventType = "enqueued"
+	// EventFetched is emitted when a job is fetched from its queue
+	EventFetched EventType = "fetched"
+	// EventRecovered is emitted when a job left in progress by a previous run is fetched again
+	EventRecovered EventType = "recovered"
+	// EventStarted is emitted when a job starts running
+	EventStarted EventType = "started"
+	// EventSucceeded is emitted when a job returns without error
+	EventSucceeded EventType = "succeeded"
+	// EventFailed is emitted when a job returns an error or panics
+	EventFailed EventType = "failed"
+	// EventRetried is emitted when a failed job is added to the retry set
+	EventRetried EventType = "retried"
+	// EventRetriesExhausted is emitted when a failed job has no retries left
+	EventRetriesExhausted EventType = "retries_exhausted"
+	// EventDead is emitted when a job is moved to the dead set
+	EventDead EventType = "dead"
+	// EventFetchError is emitted when a fetcher can't read from the store
+	EventFetchError EventType = "fetch_error"
+	// EventQuiet is emitted when the manager stops fetching new jobs, because it was quieted or is stopping
+	EventQuiet EventType = "quiet"
+	// EventStopped is emitted when all the workers of the manager have exited
+	EventStopped EventType = "stopped"
+)
+
+// DefaultEventBufferSize is the number of events buffered for a subscription when none is given
+const DefaultEventBufferSize = 100
+
+// Event describes something that happened to a job or to a manager.
+// Message is a copy of the job's message at the time of the event.
+// Message and Err are nil when they don't apply.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Queue   string
+	Message *Msg
+	Err     error
+}
+
+// EventSubscription receives the events a manager emits. Events are dropped,
+// rather than blocking the manager, when the subscription's buffer is full.
+type EventSubscription struct {
+	bus     *eventBus
+	types   map[EventType]bool
+	events  chan Event
+	dropped int64
+	once    sync.Once
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *EventSubscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (s *EventSubscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close stops the delivery of events and closes the events channel
+func (s *EventSubscription) Close() {
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+		close(s.events)
+	})
+}
+
+func (s *EventSubscription) wants(t EventType) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// eventBus fans events out to subscriptions without ever blocking the publisher
+type eventBus struct {
+	lock          sync.RWMutex
+	subscriptions []*EventSubscription
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+func (b *eventBus) subscribe(bufferSize int, types ...EventType) *EventSubscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+
+	s := &EventSubscription{
+		bus:    b,
+		types:  map[EventType]bool{},
+		events: make(chan Event, bufferSize),
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.subscriptions = append(b.subscriptions, s)
+
+	return s
+}
+
+func (b *eventBus) unsubscribe(s *EventSubscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, sub := range b.subscriptions {
+		if sub == s {
+			b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// active reports whether anybody is listening, so callers can skip building costly events
+func (b *eventBus) active() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.subscriptions) > 0
+}
+
+func (b *eventBus) publish(t EventType, queue string, message *Msg, err error) {
+	if b == nil {
+		return
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if len(b.subscriptions) == 0 {
+		return
+	}
+
+	// Subscribers get a copy, since the job and the middlewares keep changing the message
+	if message != nil {
+		message = message.snapshot()
+	}
+
+	event := Event{Type: t, Time: time.Now(), Queue: queue, Message: message, Err: err}
+	for _, s := range b.subscriptions {
+		if !s.wants(t) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events of the given types emitted by
+// the manager and its producers, or to all events when no type is given. Up to
+// bufferSize events are buffered; DefaultEventBufferSize is used when it is not positive.
+func (m *Manager) Subscribe(bufferSize int, types ...EventType) *EventSubscription {
+	return m.opts.events.subscribe(bufferSize, types...)
+}
+
+// eventsJob wraps a job to emit started, succeeded and failed events
+func eventsJob(queue string, events *eventBus, job JobFunc) JobFunc {
+	return func(message *Msg) (err error) {
+		events.publish(EventStarted, queue, message, nil)
+
+		defer func() {
+			if e := recover(); e != nil {
+				var ok bool
+				if err, ok = e.(error); !ok {
+					err = fmt.Errorf("%v", e)
+				}
+
+				events.publish(EventFailed, queue, message, err)
+				panic(e)
+			}
+		}()
+
+		err = job(message)
+		if err != nil {
+			events.publish(EventFailed, queue, message, err)
+		} else {
+			events.publish(EventSucceeded, queue, message, nil)
+		}
+
+		return
+	}
+}
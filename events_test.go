@@ -0,0 +1,171 @@
+package workers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T, s *EventSubscription) Event {
+	select {
+	case e := <-s.Events():
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	msg, _ := NewMsg(`{"jid":"1"}`)
+
+	t.Run("filters event types", func(t *testing.T) {
+		s := bus.subscribe(10, EventFailed)
+		defer s.Close()
+
+		bus.publish(EventStarted, "q", msg, nil)
+		bus.publish(EventFailed, "q", msg, errors.New("boom"))
+
+		e := nextEvent(t, s)
+		assert.Equal(t, EventFailed, e.Type)
+		assert.Equal(t, "q", e.Queue)
+		assert.Equal(t, msg, e.Message)
+		assert.EqualError(t, e.Err, "boom")
+		assert.Len(t, s.Events(), 0)
+	})
+
+	t.Run("drops events when the buffer is full", func(t *testing.T) {
+		s := bus.subscribe(2)
+		defer s.Close()
+
+		for i := 0; i < 5; i++ {
+			bus.publish(EventStarted, "q", msg, nil)
+		}
+
+		assert.Len(t, s.Events(), 2)
+		assert.Equal(t, int64(3), s.Dropped())
+	})
+
+	t.Run("close unsubscribes", func(t *testing.T) {
+		s := bus.subscribe(0)
+		assert.Equal(t, DefaultEventBufferSize, cap(s.Events()))
+		assert.True(t, bus.active())
+
+		s.Close()
+		s.Close()
+		assert.False(t, bus.active())
+
+		bus.publish(EventStarted, "q", msg, nil)
+		_, ok := <-s.Events()
+		assert.False(t, ok)
+	})
+
+	t.Run("nil bus", func(t *testing.T) {
+		var nilBus *eventBus
+		assert.False(t, nilBus.active())
+		nilBus.publish(EventStarted, "q", msg, nil)
+	})
+}
+
+func TestEventsJob(t *testing.T) {
+	bus := newEventBus()
+	s := bus.subscribe(10)
+	defer s.Close()
+	msg, _ := NewMsg(`{"jid":"1"}`)
+
+	assert.NoError(t, eventsJob("q", bus, func(m *Msg) error { return nil })(msg))
+	assert.Equal(t, EventStarted, nextEvent(t, s).Type)
+	assert.Equal(t, EventSucceeded, nextEvent(t, s).Type)
+
+	assert.Error(t, eventsJob("q", bus, func(m *Msg) error { return errors.New("boom") })(msg))
+	assert.Equal(t, EventStarted, nextEvent(t, s).Type)
+	e := nextEvent(t, s)
+	assert.Equal(t, EventFailed, e.Type)
+	assert.EqualError(t, e.Err, "boom")
+
+	assert.Panics(t, func() {
+		eventsJob("q", bus, func(m *Msg) error { panic("oops") })(msg)
+	})
+	assert.Equal(t, EventStarted, nextEvent(t, s).Type)
+	e = nextEvent(t, s)
+	assert.Equal(t, EventFailed, e.Type)
+	assert.EqualError(t, e.Err, "oops")
+}
+
+func TestManager_Subscribe(t *testing.T) {
+	opts := testOptionsWithNamespace("eventstest")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	s := mgr.Subscribe(100)
+	defer s.Close()
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F, NopMiddleware)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	jid, err := mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+	cc.ackSyncCh <- true
+
+	for _, expected := range []EventType{EventEnqueued, EventFetched, EventStarted, EventSucceeded} {
+		e := nextEvent(t, s)
+		assert.Equal(t, expected, e.Type)
+		assert.Equal(t, "queue1", e.Queue)
+		assert.Equal(t, jid, e.Message.Jid())
+	}
+
+	mgr.Stop()
+	wg.Wait()
+
+	assert.Equal(t, EventQuiet, nextEvent(t, s).Type)
+	assert.Equal(t, EventStopped, nextEvent(t, s).Type)
+}
+
+func TestEventsJob_RetrySnapshot(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.events = newEventBus()
+	mgr := &Manager{opts: opts}
+
+	s := mgr.Subscribe(10)
+	defer s.Close()
+
+	// The subscriber reads the messages while the retry middleware is still updating the job's
+	received := make(chan []string)
+	go func() {
+		var payloads []string
+		for e := range s.Events() {
+			payloads = append(payloads, e.Message.ToJson())
+			if e.Type == EventRetried {
+				break
+			}
+		}
+		received <- payloads
+	}()
+
+	message, _ := NewMsg(`{"jid":"2","retry":true}`)
+	job := NewMiddlewares(RetryMiddleware).build(opts.Namespace+"myqueue", mgr, eventsJob("myqueue", opts.events, func(m *Msg) error {
+		return errors.New("boom")
+	}))
+	assert.NoError(t, job(message))
+
+	payloads := <-received
+	assert.Len(t, payloads, 3)
+	assert.Equal(t, `{"jid":"2","retry":true}`, payloads[0])
+	assert.Equal(t, `{"jid":"2","retry":true}`, payloads[1])
+	assert.Contains(t, payloads[2], `"error_message":"boom"`)
+	assert.Contains(t, payloads[2], `"retry_count":0`)
+}
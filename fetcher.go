@@ -3,14 +3,29 @@ package workers
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/pioneerworks/go-sidekiq/storage"
 )
 
-//Fetcher is an interface for managing work messages
+// Fetcher is an interface for managing work messages. Each time the manager runs,
+// a worker creates its fetcher and uses it as follows:
+//
+//   - Fetch is called in its own goroutine, and fetches messages until Close is called.
+//   - Each runner of the worker sends true on Ready when it can process a message, then
+//     receives the next message from Messages. Fetchers should only fetch a message after
+//     a Ready signal, so that they don't hold messages that no runner will process.
+//   - Acknowledge is called with each message that was processed, or moved to the retry
+//     set, and must remove it from the messages kept in progress. It can be called after
+//     Close, for the messages that were running when the manager stopped. Messages that
+//     can't be acknowledged, for example because they couldn't be added to the retry set,
+//     are passed to the Abandon(*Msg) method of fetchers that have one.
+//   - Close stops fetching and returns once Fetch stopped. Messages that were fetched but
+//     not sent to a runner should be returned to the queue, or recovered when the manager
+//     restarts. Closed reports whether Close was called.
+//   - Queue returns the name of the queue, without namespace.
 type Fetcher interface {
 	Queue() string
 	Fetch()
@@ -21,6 +36,34 @@ type Fetcher interface {
 	Closed() bool
 }
 
+// FetcherConfig is passed to a FetcherFactory with what a fetcher needs to know about the manager
+type FetcherConfig struct {
+	Queue     string
+	Namespace string
+	ProcessID string
+	Store     storage.Store
+	Logger    *slog.Logger
+
+	// OnError reports errors reading from the store, which are counted in the stats
+	// and passed to the error handlers of the manager
+	OnError func(err error)
+}
+
+// FetcherFactory creates the fetcher of a worker each time the manager runs
+type FetcherFactory func(config FetcherConfig) Fetcher
+
+// abandoner is implemented by fetchers that need to know about the messages
+// that were processed but not acknowledged, for example because they couldn't be retried
+type abandoner interface {
+	Abandon(*Msg)
+}
+
+// fetchMonitor is implemented by fetchers that report how long their fetch loop has
+// been waiting for the store, so that the health checks can detect stuck fetchers
+type fetchMonitor interface {
+	fetchingSince() time.Time
+}
+
 type simpleFetcher struct {
 	store     storage.Store
 	processID string
@@ -30,16 +73,32 @@ type simpleFetcher struct {
 	stop      chan bool
 	exit      chan bool
 	closed    chan bool
-	logger    *log.Logger
+	logger    *slog.Logger
+	events    *eventBus
+
+	// priority fetches the jobs of the priority set before the ones of the queue
+	priority bool
+
+	// Start of the store call in progress in the fetch loop, in Unix nanoseconds, or 0
+	fetchStartedAt int64
+
+	// onError is notified of errors reading from the store, when set
+	onError func(queue string, err error)
+	// onInvalidMessage is notified of payloads that can't be parsed, when set
+	onInvalidMessage func(queue string, message string, err error)
+
+	// moveToQuarantine removes a payload that can't be parsed from the messages
+	// being processed and adds entry to the quarantine set
+	moveToQuarantine func(ctx context.Context, message string, score float64, entry string) error
 }
 
 func newSimpleFetcher(queue string, opts Options) *simpleFetcher {
 	logger := opts.Logger
 	if logger == nil {
-		logger = log.New(os.Stdout, "go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+		logger = NewLogger(slog.LevelInfo, false)
 	}
 
-	return &simpleFetcher{
+	f := &simpleFetcher{
 		store:     opts.store,
 		processID: opts.ProcessID,
 		queue:     queue,
@@ -49,7 +108,13 @@ func newSimpleFetcher(queue string, opts Options) *simpleFetcher {
 		exit:      make(chan bool),
 		closed:    make(chan bool),
 		logger:    logger,
+		events:    opts.events,
+		priority:  opts.PriorityFetch,
+	}
+	f.moveToQuarantine = func(ctx context.Context, message string, score float64, entry string) error {
+		return f.store.QuarantineMessage(ctx, f.inprogressQueue(), message, score, entry)
 	}
+	return f
 }
 
 func (f *simpleFetcher) Queue() string {
@@ -61,7 +126,7 @@ func (f *simpleFetcher) processOldMessages() {
 
 	for _, message := range messages {
 		<-f.Ready()
-		f.sendMessage(message)
+		f.sendMessage(message, EventRecovered)
 	}
 }
 
@@ -75,6 +140,10 @@ func (f *simpleFetcher) Fetch() {
 				break
 			}
 			<-f.Ready()
+			// Don't fetch a job when the fetcher was closed while waiting for a ready runner
+			if f.Closed() {
+				break
+			}
 			f.tryFetchMessage()
 		}
 	}()
@@ -92,27 +161,102 @@ func (f *simpleFetcher) Fetch() {
 }
 
 func (f *simpleFetcher) tryFetchMessage() {
+	if f.priority && f.tryFetchPriorityMessage() {
+		return
+	}
+
+	f.startFetch()
 	message, err := f.store.DequeueMessage(context.Background(), f.queue, f.inprogressQueue(), 1*time.Second)
+	f.endFetch()
 	if err != nil {
 		// If redis returns null, the queue is empty.
 		// Just ignore empty queue errors; print all other errors.
 		if err != storage.NoMessage {
-			f.logger.Println("ERR: ", f.queue, err)
+			f.logger.Error("couldn't fetch message", LogKeyQueue, f.queue, LogKeyError, err)
+			f.reportError(err)
 		}
 	} else {
-		f.sendMessage(message)
+		f.sendMessage(message, EventFetched)
 	}
 }
 
-func (f *simpleFetcher) sendMessage(message string) {
+// tryFetchPriorityMessage sends the job with the highest priority of the queue, and returns
+// false when there is none
+func (f *simpleFetcher) tryFetchPriorityMessage() bool {
+	f.startFetch()
+	message, err := f.store.DequeuePriorityMessage(context.Background(), f.queue, f.inprogressQueue())
+	f.endFetch()
+	if err != nil {
+		if err != storage.NoMessage {
+			f.logger.Error("couldn't fetch message", LogKeyQueue, f.queue, LogKeyError, err)
+			f.reportError(err)
+		}
+		return false
+	}
+
+	f.sendMessage(message, EventFetched)
+	return true
+}
+
+func (f *simpleFetcher) startFetch() {
+	atomic.StoreInt64(&f.fetchStartedAt, time.Now().UnixNano())
+}
+
+func (f *simpleFetcher) endFetch() {
+	atomic.StoreInt64(&f.fetchStartedAt, 0)
+}
+
+// fetchingSince returns when the store call in progress in the fetch loop started,
+// or the zero time when the loop isn't waiting for the store
+func (f *simpleFetcher) fetchingSince() time.Time {
+	startedAt := atomic.LoadInt64(&f.fetchStartedAt)
+	if startedAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, startedAt)
+}
+
+func (f *simpleFetcher) sendMessage(message string, event EventType) {
+	msg := f.parseMessage(message)
+	if msg == nil {
+		return
+	}
+
+	f.events.publish(event, f.queue, msg, nil)
+	f.Messages() <- msg
+}
+
+// parseMessage returns the message for a fetched payload, or quarantines it and returns nil when it can't be parsed
+func (f *simpleFetcher) parseMessage(message string) *Msg {
 	msg, err := NewMsg(message)
 
 	if err != nil {
-		f.logger.Println("ERR: Couldn't create message from", message, ":", err)
+		f.logger.Error("couldn't create message", LogKeyQueue, f.queue, "message", message, LogKeyError, err)
+		f.quarantine(message, err)
+		if f.onInvalidMessage != nil {
+			f.onInvalidMessage(f.queue, message, err)
+		}
+		return nil
+	}
+
+	return msg
+}
+
+// quarantine moves a payload that can't be parsed from the in progress list (or
+// lease set) to the quarantine set, so that it isn't fetched again when the fetcher restarts
+func (f *simpleFetcher) quarantine(message string, parseErr error) {
+	ctx := context.Background()
+
+	now := nowToSecondsWithNanoPrecision()
+	entry := newQuarantineEntry(f.queue, message, parseErr, now)
+	if err := f.moveToQuarantine(ctx, message, now, entry); err != nil {
+		f.logger.Error("couldn't quarantine message", LogKeyQueue, f.queue, LogKeyError, err)
 		return
 	}
 
-	f.Messages() <- msg
+	if err := f.store.IncrementStats(ctx, "quarantined"); err != nil {
+		f.logger.Error("couldn't save stats", LogKeyError, err)
+	}
 }
 
 func (f *simpleFetcher) Acknowledge(message *Msg) {
@@ -144,12 +288,19 @@ func (f *simpleFetcher) Closed() bool {
 func (f *simpleFetcher) inprogressMessages() []string {
 	messages, err := f.store.ListMessages(context.Background(), f.inprogressQueue())
 	if err != nil {
-		f.logger.Println("ERR: ", err)
+		f.logger.Error("couldn't list in progress messages", LogKeyQueue, f.queue, LogKeyError, err)
+		f.reportError(err)
 	}
 
 	return messages
 }
 
+func (f *simpleFetcher) reportError(err error) {
+	if f.onError != nil {
+		f.onError(f.queue, err)
+	}
+}
+
 func (f *simpleFetcher) inprogressQueue() string {
 	return fmt.Sprint(f.queue, ":", f.processID, ":inprogress")
 }
@@ -2,7 +2,9 @@ package workers
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -162,3 +164,140 @@ func TestRetryInprogressMessages(t *testing.T) {
 
 	fetch.Close()
 }
+
+// channelFetcher gets messages from a channel instead of the store, like a fetcher for an external broker
+type channelFetcher struct {
+	config   FetcherConfig
+	source   chan string
+	ready    chan bool
+	messages chan *Msg
+	stop     chan bool
+	closed   chan bool
+	acked    chan *Msg
+}
+
+func (f *channelFetcher) Queue() string       { return f.config.Queue }
+func (f *channelFetcher) Ready() chan bool    { return f.ready }
+func (f *channelFetcher) Messages() chan *Msg { return f.messages }
+func (f *channelFetcher) Acknowledge(m *Msg)  { f.acked <- m }
+
+func (f *channelFetcher) Fetch() {
+	for {
+		select {
+		case <-f.ready:
+			select {
+			case message := <-f.source:
+				msg, err := NewMsg(message)
+				if err != nil {
+					f.config.OnError(err)
+					continue
+				}
+				f.messages <- msg
+			case <-f.stop:
+				close(f.closed)
+				return
+			}
+		case <-f.stop:
+			close(f.closed)
+			return
+		}
+	}
+}
+
+func (f *channelFetcher) Close() {
+	f.stop <- true
+	<-f.closed
+}
+
+func (f *channelFetcher) Closed() bool {
+	select {
+	case <-f.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestManager_AddWorkerWithFetcher(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	source := make(chan string)
+	acked := make(chan *Msg, 1)
+	var config FetcherConfig
+	newFetcher := func(c FetcherConfig) Fetcher {
+		config = c
+		return &channelFetcher{
+			config:   c,
+			source:   source,
+			ready:    make(chan bool),
+			messages: make(chan *Msg),
+			stop:     make(chan bool),
+			closed:   make(chan bool),
+			acked:    acked,
+		}
+	}
+
+	processed := make(chan *Msg, 1)
+	mgr.AddWorkerWithFetcher("queue1", 1, newFetcher, func(m *Msg) error {
+		processed <- m
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	source <- `{"jid":"1","class":"Add","args":[]}`
+	assert.Equal(t, "1", (<-processed).Jid())
+	assert.Equal(t, "1", (<-acked).Jid())
+
+	// Errors reported by the fetcher are counted like the ones of the built-in fetchers
+	source <- "not json"
+	assert.Eventually(t, func() bool {
+		return mgr.fetchErrorCounts()["queue1"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mgr.Stop()
+	wg.Wait()
+
+	assert.Equal(t, "queue1", config.Queue)
+	assert.Equal(t, "prod:", config.Namespace)
+	assert.Equal(t, "1", config.ProcessID)
+	assert.NotNil(t, config.Store)
+}
+
+func TestPriorityFetch(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+	opts.PriorityFetch = true
+
+	normal, _ := NewMsg("{\"foo\":\"normal\"}")
+	low, _ := NewMsg("{\"foo\":\"low\",\"priority\":1}")
+	high, _ := NewMsg("{\"foo\":\"high\",\"priority\":10}")
+	opts.store.EnqueueMessageNow(ctx, "priorityQueue", normal.ToJson())
+	opts.store.EnqueueMessage(ctx, "priorityQueue", 1, low.ToJson())
+	opts.store.EnqueueMessage(ctx, "priorityQueue", 10, high.ToJson())
+
+	fetch := buildFetch("priorityQueue", opts)
+
+	for _, expected := range []*Msg{high, low, normal} {
+		fetch.Ready() <- true
+		message := <-fetch.Messages()
+		assert.Equal(t, expected, message)
+		fetch.Acknowledge(message)
+	}
+
+	len, err := opts.client.LLen(ctx, "queue:priorityQueue:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), len)
+
+	fetch.Close()
+}
@@ -0,0 +1,89 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HealthCheck is the result of one of the health checks of a manager
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Health contains the result of the health checks of a manager. A manager is healthy
+// when the store can be reached and its fetchers and scheduler aren't stuck, and it is
+// ready when it is also running and not quiet.
+type Health struct {
+	Name    string        `json:"manager_name"`
+	Running bool          `json:"running"`
+	Quiet   bool          `json:"quiet"`
+	Healthy bool          `json:"healthy"`
+	Ready   bool          `json:"ready"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// CheckHealth checks that the store can be reached and that the fetchers and the
+// scheduler of the manager aren't stuck, for longer than Options.HealthTimeout
+func (m *Manager) CheckHealth(ctx context.Context) Health {
+	m.lock.Lock()
+	health := Health{
+		Name:    m.opts.ManagerDisplayName,
+		Running: m.running,
+		Quiet:   m.running && m.quiet,
+	}
+	// Stopped schedulers don't poll anymore
+	schedule := m.schedule
+	if !m.running || m.quiet || m.stopping {
+		schedule = nil
+	}
+	workers := append([]*worker(nil), m.workers...)
+	m.lock.Unlock()
+
+	timeout := m.opts.HealthTimeout
+	now := time.Now()
+
+	check := HealthCheck{Name: "store", OK: true}
+	if err := m.opts.store.Ping(ctx); err != nil {
+		check.OK = false
+		check.Error = err.Error()
+	}
+	health.Checks = append(health.Checks, check)
+
+	for _, w := range workers {
+		w.runnersLock.Lock()
+		fetcher := w.fetcher
+		w.runnersLock.Unlock()
+
+		monitor, ok := fetcher.(fetchMonitor)
+		if !ok {
+			continue
+		}
+
+		check := HealthCheck{Name: "fetcher:" + w.queue, OK: true}
+		if since := monitor.fetchingSince(); !since.IsZero() && now.Sub(since) > timeout {
+			check.OK = false
+			check.Error = fmt.Sprintf("fetch running for %s", now.Sub(since).Round(time.Second))
+		}
+		health.Checks = append(health.Checks, check)
+	}
+
+	if schedule != nil {
+		check := HealthCheck{Name: "scheduler", OK: true}
+		if since := now.Sub(schedule.lastPolled()); since > m.opts.PollInterval+timeout {
+			check.OK = false
+			check.Error = fmt.Sprintf("last poll %s ago", since.Round(time.Second))
+		}
+		health.Checks = append(health.Checks, check)
+	}
+
+	health.Healthy = true
+	for _, check := range health.Checks {
+		health.Healthy = health.Healthy && check.OK
+	}
+	health.Ready = health.Healthy && health.Running && !health.Quiet
+
+	return health
+}
@@ -0,0 +1,58 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_CheckHealth(t *testing.T) {
+	ctx := context.Background()
+
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	opts.HealthTimeout = time.Minute
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	health := mgr.CheckHealth(ctx)
+	assert.True(t, health.Healthy)
+	assert.False(t, health.Running)
+	assert.False(t, health.Ready)
+	assert.Equal(t, []HealthCheck{{Name: "store", OK: true}}, health.Checks)
+
+	// A running manager, with a fetcher and a scheduler that are stuck
+	fetcher := newSimpleFetcher("queue1", mgr.opts)
+	fetcher.fetchStartedAt = time.Now().Add(-2 * time.Minute).UnixNano()
+	mgr.workers[0].fetcher = fetcher
+	mgr.schedule = newScheduledWorker(mgr.opts)
+	mgr.schedule.lastPoll = time.Now().Add(-2 * time.Minute).UnixNano()
+	mgr.running = true
+
+	health = mgr.CheckHealth(ctx)
+	assert.False(t, health.Healthy)
+	assert.False(t, health.Ready)
+	if assert.Len(t, health.Checks, 3) {
+		assert.True(t, health.Checks[0].OK)
+		assert.Equal(t, HealthCheck{Name: "fetcher:queue1", Error: "fetch running for 2m0s"}, health.Checks[1])
+		assert.Equal(t, HealthCheck{Name: "scheduler", Error: "last poll 2m0s ago"}, health.Checks[2])
+	}
+
+	fetcher.endFetch()
+	mgr.schedule.lastPoll = time.Now().UnixNano()
+
+	health = mgr.CheckHealth(ctx)
+	assert.True(t, health.Healthy)
+	assert.True(t, health.Ready)
+
+	// Quiet managers are healthy, but not ready, and their scheduler stopped
+	mgr.quiet = true
+	health = mgr.CheckHealth(ctx)
+	assert.True(t, health.Healthy)
+	assert.True(t, health.Quiet)
+	assert.False(t, health.Ready)
+	assert.Len(t, health.Checks, 2)
+}
@@ -0,0 +1,236 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// jobMetricsBuckets are the upper bounds, in seconds, of the buckets of the job duration
+// histograms. The stored counts are indexed by bucket, so the bounds can't change at runtime.
+var jobMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// JobMetrics contains the execution metrics of a class of jobs in a queue
+type JobMetrics struct {
+	Queue         string    `json:"queue"`
+	Class         string    `json:"class"`
+	Processed     int64     `json:"processed"`
+	Failed        int64     `json:"failed"`
+	ExecutionTime Histogram `json:"execution_time"`
+	QueueTime     Histogram `json:"queue_time"`
+}
+
+// Histogram summarizes durations measured in seconds
+type Histogram struct {
+	Count   int64             `json:"count"`
+	Sum     float64           `json:"sum"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket contains the number of observations less than or equal to its upper bound
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+// GetJobMetrics returns the execution metrics of every class of jobs that has been processed
+func (m *Manager) GetJobMetrics() ([]JobMetrics, error) {
+	stored, err := m.opts.store.GetJobMetrics(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []JobMetrics{}
+	for _, s := range stored {
+		metrics = append(metrics, JobMetrics{
+			Queue:         s.Queue,
+			Class:         s.Class,
+			Processed:     s.Processed,
+			Failed:        s.Failed,
+			ExecutionTime: newHistogram(s.ExecutionTime),
+			QueueTime:     newHistogram(s.QueueTime),
+		})
+	}
+
+	return metrics, nil
+}
+
+// newHistogram converts stored per bucket counts into cumulative buckets
+func newHistogram(h storage.Histogram) Histogram {
+	histogram := Histogram{
+		Count:   h.Count,
+		Sum:     h.Sum,
+		Buckets: make([]HistogramBucket, len(jobMetricsBuckets)),
+	}
+
+	var cumulative int64
+	for i, bound := range jobMetricsBuckets {
+		if i < len(h.Buckets) {
+			cumulative += h.Buckets[i]
+		}
+		histogram.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+
+	return histogram
+}
+
+type jobMetricsKey struct {
+	queue string
+	class string
+}
+
+// jobMetricsCollector aggregates job metrics in memory until they are flushed to the store
+type jobMetricsCollector struct {
+	lock    sync.Mutex
+	metrics map[jobMetricsKey]*storage.JobMetrics
+}
+
+func newJobMetricsCollector() *jobMetricsCollector {
+	return &jobMetricsCollector{
+		metrics: map[jobMetricsKey]*storage.JobMetrics{},
+	}
+}
+
+// observe records the outcome of a job. The queue time is ignored when negative.
+func (c *jobMetricsCollector) observe(queue string, class string, failed bool, execution time.Duration, queueTime time.Duration) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := jobMetricsKey{queue: queue, class: class}
+	m, ok := c.metrics[key]
+	if !ok {
+		m = &storage.JobMetrics{Queue: queue, Class: class}
+		c.metrics[key] = m
+	}
+
+	if failed {
+		m.Failed++
+	} else {
+		m.Processed++
+	}
+
+	observeHistogram(&m.ExecutionTime, execution.Seconds())
+	if queueTime >= 0 {
+		observeHistogram(&m.QueueTime, queueTime.Seconds())
+	}
+}
+
+func observeHistogram(h *storage.Histogram, seconds float64) {
+	if h.Buckets == nil {
+		// The extra bucket counts observations above the last upper bound
+		h.Buckets = make([]int64, len(jobMetricsBuckets)+1)
+	}
+
+	h.Count++
+	h.Sum += seconds
+	h.Buckets[sort.SearchFloat64s(jobMetricsBuckets, seconds)]++
+}
+
+// take removes and returns the metrics collected so far
+func (c *jobMetricsCollector) take() []storage.JobMetrics {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	metrics := make([]storage.JobMetrics, 0, len(c.metrics))
+	for _, m := range c.metrics {
+		metrics = append(metrics, *m)
+	}
+	c.metrics = map[jobMetricsKey]*storage.JobMetrics{}
+
+	return metrics
+}
+
+// restore adds back metrics that couldn't be flushed
+func (c *jobMetricsCollector) restore(metrics []storage.JobMetrics) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, m := range metrics {
+		key := jobMetricsKey{queue: m.Queue, class: m.Class}
+		existing, ok := c.metrics[key]
+		if !ok {
+			copied := m
+			c.metrics[key] = &copied
+			continue
+		}
+
+		existing.Processed += m.Processed
+		existing.Failed += m.Failed
+		mergeHistogram(&existing.ExecutionTime, m.ExecutionTime)
+		mergeHistogram(&existing.QueueTime, m.QueueTime)
+	}
+}
+
+func mergeHistogram(h *storage.Histogram, other storage.Histogram) {
+	if h.Buckets == nil {
+		h.Buckets = make([]int64, len(other.Buckets))
+	}
+
+	h.Count += other.Count
+	h.Sum += other.Sum
+	for i, count := range other.Buckets {
+		h.Buckets[i] += count
+	}
+}
+
+// flush writes the collected metrics to the store
+func (c *jobMetricsCollector) flush(ctx context.Context, store storage.Store) error {
+	metrics := c.take()
+	if err := store.IncrementJobMetrics(ctx, metrics); err != nil {
+		c.restore(metrics)
+		return err
+	}
+	return nil
+}
+
+// jobMetricsFlusher periodically flushes a collector while the manager runs
+type jobMetricsFlusher struct {
+	opts      Options
+	collector *jobMetricsCollector
+	logger    *slog.Logger
+	done      chan bool
+}
+
+func newJobMetricsFlusher(opts Options, collector *jobMetricsCollector) *jobMetricsFlusher {
+	return &jobMetricsFlusher{
+		opts:      opts,
+		collector: collector,
+		logger:    opts.Logger,
+		done:      make(chan bool),
+	}
+}
+
+// run flushes the collector until the flusher quits, and returns the error of the last flush
+func (f *jobMetricsFlusher) run() error {
+	ticker := time.NewTicker(f.opts.MetricsFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.flush()
+		case <-f.done:
+			return f.flush()
+		}
+	}
+}
+
+func (f *jobMetricsFlusher) flush() error {
+	err := f.collector.flush(context.Background(), f.opts.store)
+	if err != nil {
+		f.logger.Error("couldn't save job metrics", LogKeyError, err)
+	}
+	return err
+}
+
+func (f *jobMetricsFlusher) quit() {
+	close(f.done)
+}
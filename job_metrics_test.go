@@ -0,0 +1,98 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobMetricsCollector(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+
+	c := newJobMetricsCollector()
+	c.observe("queue1", "Add", false, 20*time.Millisecond, 3*time.Second)
+	c.observe("queue1", "Add", true, 2*time.Second, -1)
+	c.observe("queue2", "Subtract", false, time.Hour, time.Millisecond)
+
+	assert.NoError(t, c.flush(ctx, opts.store))
+	assert.Empty(t, c.take())
+
+	c.observe("queue1", "Add", false, time.Millisecond, time.Millisecond)
+	assert.NoError(t, c.flush(ctx, opts.store))
+
+	metrics, err := mgr.GetJobMetrics()
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+
+	add := metrics[0]
+	assert.Equal(t, "queue1", add.Queue)
+	assert.Equal(t, "Add", add.Class)
+	assert.Equal(t, int64(2), add.Processed)
+	assert.Equal(t, int64(1), add.Failed)
+	assert.Equal(t, int64(3), add.ExecutionTime.Count)
+	assert.InDelta(t, 2.021, add.ExecutionTime.Sum, 0.0001)
+	assert.Len(t, add.ExecutionTime.Buckets, len(jobMetricsBuckets))
+	assert.Equal(t, HistogramBucket{UpperBound: 0.005, Count: 1}, add.ExecutionTime.Buckets[0])
+	assert.Equal(t, HistogramBucket{UpperBound: 0.025, Count: 2}, add.ExecutionTime.Buckets[2])
+	assert.Equal(t, HistogramBucket{UpperBound: 2.5, Count: 3}, add.ExecutionTime.Buckets[8])
+	assert.Equal(t, int64(2), add.QueueTime.Count)
+
+	subtract := metrics[1]
+	assert.Equal(t, "queue2", subtract.Queue)
+	assert.Equal(t, int64(1), subtract.ExecutionTime.Count)
+	// Observations above the last bucket are only part of the count
+	assert.Equal(t, int64(0), subtract.ExecutionTime.Buckets[len(jobMetricsBuckets)-1].Count)
+}
+
+func TestJobMetricsCollector_Restore(t *testing.T) {
+	c := newJobMetricsCollector()
+	c.observe("queue1", "Add", false, time.Millisecond, -1)
+
+	taken := c.take()
+	c.observe("queue1", "Add", true, time.Millisecond, -1)
+	c.restore(taken)
+
+	metrics := c.take()
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1), metrics[0].Processed)
+	assert.Equal(t, int64(1), metrics[0].Failed)
+	assert.Equal(t, int64(2), metrics[0].ExecutionTime.Count)
+	assert.Equal(t, int64(0), metrics[0].QueueTime.Count)
+}
+
+func TestStatsMiddleware_JobMetrics(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts, jobMetrics: newJobMetricsCollector()}
+
+	message, _ := NewMsg(`{"jid":"2","class":"Add","enqueued_at":` + formatSeconds(nowToSecondsWithNanoPrecision()-5) + `}`)
+	NewMiddlewares(StatsMiddleware).build("prod:myqueue", mgr, func(m *Msg) error {
+		return nil
+	})(message)
+	NewMiddlewares(StatsMiddleware).build("prod:myqueue", mgr, func(m *Msg) error {
+		return errors.New("AHHHH")
+	})(message)
+
+	assert.NoError(t, mgr.jobMetrics.flush(context.Background(), opts.store))
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats.JobMetrics, 1)
+	assert.Equal(t, "myqueue", stats.JobMetrics[0].Queue)
+	assert.Equal(t, "Add", stats.JobMetrics[0].Class)
+	assert.Equal(t, int64(1), stats.JobMetrics[0].Processed)
+	assert.Equal(t, int64(1), stats.JobMetrics[0].Failed)
+	assert.Equal(t, int64(2), stats.JobMetrics[0].QueueTime.Count)
+	assert.InDelta(t, 10, stats.JobMetrics[0].QueueTime.Sum, 1)
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
@@ -0,0 +1,188 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// DefaultLeaseDuration is the duration of the leases taken by the lease fetcher when Options.LeaseDuration isn't set
+const DefaultLeaseDuration = time.Minute
+
+// MinLeaseDuration is the shortest Options.LeaseDuration, since leases are extended every third of their duration
+const MinLeaseDuration = time.Second
+
+// leaseFetcher moves fetched messages to a set of leases instead of an in progress
+// list per process. Leases are extended while their messages are processed, and
+// leases that expired, because the manager holding them went away, are moved back
+// to the queue by any manager fetching from it.
+type leaseFetcher struct {
+	*simpleFetcher
+
+	leaseDuration time.Duration
+
+	// held counts the leased messages that haven't been acknowledged, by payload
+	heldLock sync.Mutex
+	held     map[string]int
+}
+
+func newLeaseFetcher(queue string, opts Options) *leaseFetcher {
+	f := &leaseFetcher{
+		simpleFetcher: newSimpleFetcher(queue, opts),
+		leaseDuration: opts.LeaseDuration,
+		held:          map[string]int{},
+	}
+	if f.leaseDuration <= 0 {
+		f.leaseDuration = DefaultLeaseDuration
+	}
+	f.moveToQuarantine = func(ctx context.Context, message string, score float64, entry string) error {
+		return f.store.QuarantineLeasedMessage(ctx, f.queue, message, score, entry)
+	}
+	return f
+}
+
+func (f *leaseFetcher) Fetch() {
+	f.reclaimExpiredLeases()
+
+	go f.extendLeases()
+
+	go func() {
+		for {
+			// f.Close() has been called
+			if f.Closed() {
+				break
+			}
+			<-f.Ready()
+			// Don't fetch a job when the fetcher was closed while waiting for a ready runner
+			if f.Closed() {
+				break
+			}
+			f.tryLeaseMessage()
+		}
+	}()
+
+	<-f.stop
+	// Stop the polling goroutine
+	close(f.closed)
+	// Signal to Close() that the fetcher has stopped
+	close(f.exit)
+}
+
+func (f *leaseFetcher) tryLeaseMessage() {
+	ctx := context.Background()
+
+	f.startFetch()
+	message, err := f.store.LeaseMessage(ctx, f.queue, f.leaseExpiry(), 1*time.Second)
+	f.endFetch()
+	if err != nil {
+		if err != storage.NoMessage {
+			f.logger.Error("couldn't fetch message", LogKeyQueue, f.queue, LogKeyError, err)
+			f.reportError(err)
+		}
+		return
+	}
+
+	msg := f.parseMessage(message)
+	if msg == nil {
+		return
+	}
+
+	f.hold(message)
+	f.events.publish(EventFetched, f.queue, msg, nil)
+
+	select {
+	case f.Messages() <- msg:
+	case <-f.closed:
+		// No runner is left to process the message, so let another manager reclaim it
+		f.expire(message)
+	}
+}
+
+// extendLeases periodically extends the leases of the messages being processed and
+// reclaims expired leases, until the fetcher is closed and the messages are done
+func (f *leaseFetcher) extendLeases() {
+	ticker := time.NewTicker(f.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		held := f.heldMessages()
+		if f.Closed() && len(held) == 0 {
+			return
+		}
+
+		expiry := f.leaseExpiry()
+		for _, message := range held {
+			extended, err := f.store.ExtendLease(context.Background(), f.queue, message, expiry)
+			if err != nil {
+				f.logger.Error("couldn't extend lease", LogKeyQueue, f.queue, LogKeyError, err)
+				f.reportError(err)
+			} else if !extended {
+				f.logger.Warn("lease expired before it was extended", LogKeyQueue, f.queue)
+			}
+		}
+
+		if !f.Closed() {
+			f.reclaimExpiredLeases()
+		}
+	}
+}
+
+func (f *leaseFetcher) reclaimExpiredLeases() {
+	reclaimed, err := f.store.ReclaimExpiredLeases(context.Background(), f.queue, nowToSecondsWithNanoPrecision())
+	if err != nil {
+		f.logger.Error("couldn't reclaim expired leases", LogKeyQueue, f.queue, LogKeyError, err)
+		f.reportError(err)
+	} else if reclaimed > 0 {
+		f.logger.Info("reclaimed expired leases", LogKeyQueue, f.queue, "count", reclaimed)
+	}
+}
+
+func (f *leaseFetcher) Acknowledge(message *Msg) {
+	f.release(message.OriginalJson())
+	f.store.ReleaseLease(context.Background(), f.queue, message.OriginalJson())
+}
+
+// Abandon lets the lease of a message that wasn't acknowledged expire, so that
+// it is fetched again once reclaimed
+func (f *leaseFetcher) Abandon(message *Msg) {
+	f.expire(message.OriginalJson())
+}
+
+func (f *leaseFetcher) expire(message string) {
+	f.release(message)
+	if _, err := f.store.ExtendLease(context.Background(), f.queue, message, 0); err != nil {
+		f.logger.Error("couldn't expire lease", LogKeyQueue, f.queue, LogKeyError, err)
+	}
+}
+
+func (f *leaseFetcher) leaseExpiry() float64 {
+	return nowToSecondsWithNanoPrecision() + durationToSecondsWithNanoPrecision(f.leaseDuration)
+}
+
+func (f *leaseFetcher) hold(message string) {
+	f.heldLock.Lock()
+	defer f.heldLock.Unlock()
+	f.held[message]++
+}
+
+func (f *leaseFetcher) release(message string) {
+	f.heldLock.Lock()
+	defer f.heldLock.Unlock()
+	if f.held[message] <= 1 {
+		delete(f.held, message)
+	} else {
+		f.held[message]--
+	}
+}
+
+func (f *leaseFetcher) heldMessages() []string {
+	f.heldLock.Lock()
+	defer f.heldLock.Unlock()
+	messages := make([]string, 0, len(f.held))
+	for message := range f.held {
+		messages = append(messages, message)
+	}
+	return messages
+}
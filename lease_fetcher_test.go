@@ -0,0 +1,198 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildLeaseFetch(queue string, opts Options) *leaseFetcher {
+	fetch := newLeaseFetcher(queue, opts)
+	go fetch.Fetch()
+	return fetch
+}
+
+func TestLeaseFetch(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+	opts.LeaseDuration = time.Minute
+
+	message, _ := NewMsg("{\"foo\":\"bar\"}")
+	rc := opts.client
+	rc.LPush(ctx, "queue:leaseQueue1", message.ToJson())
+
+	fetch := buildLeaseFetch("leaseQueue1", opts)
+
+	fetch.Ready() <- true
+	assert.Equal(t, message, <-fetch.Messages())
+
+	// The message is leased instead of moved to an in progress list
+	len, err := rc.LLen(ctx, "queue:leaseQueue1:1:inprogress").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), len)
+
+	leases, err := opts.store.ListLeasedMessages(ctx, "leaseQueue1")
+	assert.NoError(t, err)
+	if assert.Len(t, leases, 1) {
+		assert.Equal(t, message.ToJson(), leases[0].Message)
+		assert.InDelta(t, nowToSecondsWithNanoPrecision()+60, leases[0].Score, 5)
+	}
+
+	fetch.Acknowledge(message)
+
+	leases, err = opts.store.ListLeasedMessages(ctx, "leaseQueue1")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+	assert.Empty(t, fetch.heldMessages())
+
+	fetch.Close()
+}
+
+func TestLeaseFetch_ExtendsLeases(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+	opts.LeaseDuration = 3 * time.Second
+
+	message, _ := NewMsg("{\"foo\":\"bar\"}")
+	opts.client.LPush(ctx, "queue:leaseQueue2", message.ToJson())
+
+	fetch := buildLeaseFetch("leaseQueue2", opts)
+	fetch.Ready() <- true
+	<-fetch.Messages()
+
+	leases, err := opts.store.ListLeasedMessages(ctx, "leaseQueue2")
+	assert.NoError(t, err)
+	assert.Len(t, leases, 1)
+	expiry := leases[0].Score
+
+	// Held messages are extended every third of the lease duration, so they never expire
+	time.Sleep(4 * time.Second)
+
+	leases, err = opts.store.ListLeasedMessages(ctx, "leaseQueue2")
+	assert.NoError(t, err)
+	if assert.Len(t, leases, 1) {
+		assert.Greater(t, leases[0].Score, expiry)
+	}
+
+	fetch.Acknowledge(message)
+	fetch.Close()
+}
+
+func TestLeaseFetch_ReclaimsExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+
+	// A lease left behind by a manager that went away, with another process ID
+	message, _ := NewMsg("{\"foo\":\"bar\"}")
+	assert.NoError(t, opts.store.AddSortedSetMessage(ctx, "queue:leaseQueue3:leases", nowToSecondsWithNanoPrecision()-1, message.ToJson()))
+
+	opts.ProcessID = "2"
+	fetch := buildLeaseFetch("leaseQueue3", opts)
+
+	fetch.Ready() <- true
+	assert.Equal(t, message, <-fetch.Messages())
+
+	fetch.Acknowledge(message)
+
+	leases, err := opts.store.ListLeasedMessages(ctx, "leaseQueue3")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+
+	fetch.Close()
+}
+
+func TestLeaseFetch_Abandon(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+
+	message, _ := NewMsg("{\"foo\":\"bar\"}")
+	opts.client.LPush(ctx, "queue:leaseQueue4", message.ToJson())
+
+	fetch := buildLeaseFetch("leaseQueue4", opts)
+	fetch.Ready() <- true
+	<-fetch.Messages()
+
+	fetch.Abandon(message)
+	assert.Empty(t, fetch.heldMessages())
+
+	// The lease expired right away, so it's reclaimed by the next fetcher
+	reclaimed, err := opts.store.ReclaimExpiredLeases(ctx, "leaseQueue4", nowToSecondsWithNanoPrecision())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reclaimed)
+
+	fetch.Close()
+}
+
+func TestLeaseFetch_Quarantine(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptions()
+	assert.NoError(t, err)
+
+	opts.client.LPush(ctx, "queue:leaseQueue5", "not json")
+	message, _ := NewMsg("{\"foo\":\"bar\"}")
+	opts.client.LPush(ctx, "queue:leaseQueue5", message.ToJson())
+
+	fetch := buildLeaseFetch("leaseQueue5", opts)
+	fetch.Ready() <- true
+	fetch.Ready() <- true
+	assert.Equal(t, message, <-fetch.Messages())
+	fetch.Acknowledge(message)
+
+	leases, err := opts.store.ListLeasedMessages(ctx, "leaseQueue5")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+
+	size, err := opts.store.SortedSetSize(ctx, "quarantine")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	fetch.Close()
+}
+
+func TestManager_LeaseFetch(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.LeaseFetch = true
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 2, cc.F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	leases, err := mgr.opts.store.ListLeasedMessages(context.Background(), "queue1")
+	assert.NoError(t, err)
+	assert.Len(t, leases, 1)
+
+	cc.ackSyncCh <- true
+
+	mgr.Stop()
+	wg.Wait()
+
+	leases, err = mgr.opts.store.ListLeasedMessages(context.Background(), "queue1")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+	assert.Equal(t, 1, cc.count)
+}
@@ -0,0 +1,38 @@
+package workers
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Keys of the attributes added to log records
+const (
+	LogKeyQueue      = "queue"
+	LogKeyClass      = "class"
+	LogKeyJid        = "jid"
+	LogKeyDuration   = "duration"
+	LogKeyRetryCount = "retry_count"
+	LogKeyError      = "error"
+)
+
+// NewLogger returns a logger writing to stdout records at or above the given
+// level, as JSON objects when json is true or as key=value pairs otherwise
+func NewLogger(level slog.Leveler, json bool) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	if json {
+		return slog.New(slog.NewJSONHandler(os.Stdout, handlerOpts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, handlerOpts))
+}
+
+// messageLogAttrs returns the attributes identifying a message in log records
+func messageLogAttrs(queue string, message *Msg) []any {
+	retryCount, _ := message.Get("retry_count").Int()
+
+	return []any{
+		LogKeyQueue, queue,
+		LogKeyClass, message.Class(),
+		LogKeyJid, message.Jid(),
+		LogKeyRetryCount, retryCount,
+	}
+}
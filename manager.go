@@ -2,29 +2,54 @@ package workers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"github.com/pioneerworks/go-sidekiq/storage"
 )
 
+// ErrManagerRunning is returned when a manager that already runs is run again
+var ErrManagerRunning = errors.New("manager is already running")
+
 // Manager coordinates work, workers, and signaling needed for job processing
 type Manager struct {
 	uuid     string
 	opts     Options
 	schedule *scheduledWorker
+	flusher  *jobMetricsFlusher
+	scaler   *concurrencyScaler
 	workers  []*worker
 	lock     sync.Mutex
 	signal   chan os.Signal
 	running  bool
-	logger   *log.Logger
+	stopping bool
+	quiet    bool
+	logger   *slog.Logger
+
+	// Warns once that GetRedisClient returns nil
+	redisClientWarning sync.Once
+
+	// Tracks everything started by Run, including the workers added while it runs
+	wg sync.WaitGroup
+
+	jobMetrics *jobMetricsCollector
+
+	fetchErrorsLock sync.Mutex
+	fetchErrors     map[string]int64
 
 	beforeStartHooks []func()
 	duringDrainHooks []func()
 
 	retriesExhaustedHandlers []RetriesExhaustedFunc
+
+	errorHandlersLock sync.RWMutex
+	errorHandlers     []ErrorHandler
 }
 
 // NewManager creates a new manager with provide options
@@ -33,45 +58,123 @@ func NewManager(options Options) (*Manager, error) {
 	if err != nil {
 		return nil, err
 	}
+	options.events = newEventBus()
 
 	return &Manager{
-		uuid:   uuid.New().String(),
-		logger: options.Logger,
-		opts:   options,
+		uuid:       uuid.New().String(),
+		logger:     options.Logger,
+		opts:       options,
+		jobMetrics: newJobMetricsCollector(),
 	}, nil
 }
 
 // NewManagerWithRedisClient creates a new manager with provide options and pre-configured Redis client
-func NewManagerWithRedisClient(options Options, client *redis.Client) (*Manager, error) {
+func NewManagerWithRedisClient(options Options, client redis.UniversalClient) (*Manager, error) {
 	options, err := processOptionsWithRedisClient(options, client)
 	if err != nil {
 		return nil, err
 	}
+	options.events = newEventBus()
 
 	return &Manager{
-		uuid:   uuid.New().String(),
-		logger: options.Logger,
-		opts:   options,
+		uuid:       uuid.New().String(),
+		logger:     options.Logger,
+		opts:       options,
+		jobMetrics: newJobMetricsCollector(),
 	}, nil
 }
 
-// GetRedisClient returns the Redis client used by the manager
+// GetRedisClient returns the Redis client used by the manager, or nil when
+// it uses a cluster or another kind of universal client
+// Deprecated: use GetRedisUniversalClient, which supports every kind of client
 func (m *Manager) GetRedisClient() *redis.Client {
+	client, ok := m.opts.client.(*redis.Client)
+	if !ok {
+		m.redisClientWarning.Do(func() {
+			m.logger.Warn("GetRedisClient returns nil for cluster clients and custom stores, use GetRedisUniversalClient")
+		})
+	}
+	return client
+}
+
+// GetRedisUniversalClient returns the Redis client used by the manager
+func (m *Manager) GetRedisUniversalClient() redis.UniversalClient {
 	return m.opts.client
 }
 
 // AddWorker adds a new job processing worker
 func (m *Manager) AddWorker(queue string, concurrency int, job JobFunc, mids ...MiddlewareFunc) {
+	m.AddWorkerWithFetcher(queue, concurrency, nil, job, mids...)
+}
+
+// AddWorkerWithFetcher adds a new job processing worker that gets its jobs from the fetchers
+// created by newFetcher, or from the fetcher selected by the manager's options when it is nil
+func (m *Manager) AddWorkerWithFetcher(queue string, concurrency int, newFetcher FetcherFactory, job JobFunc, mids ...MiddlewareFunc) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
 	middlewareQueueName := m.opts.Namespace + queue
+	job = eventsJob(queue, m.opts.events, m.errorsJob(queue, job))
 	if len(mids) == 0 {
 		job = DefaultMiddlewares().build(middlewareQueueName, m, job)
 	} else {
 		job = NewMiddlewares(mids...).build(middlewareQueueName, m, job)
 	}
-	m.workers = append(m.workers, newWorker(m.logger, queue, concurrency, job))
+	w := newWorker(m.logger, queue, concurrency, job)
+	w.newFetcher = newFetcher
+	m.workers = append(m.workers, w)
+
+	// Workers added while the manager runs start right away, unless it was quieted
+	if m.running && !m.stopping && !m.quiet {
+		m.startWorker(w)
+	}
+}
+
+// RemoveWorker removes the workers of a queue. When the manager runs, their fetchers
+// stop right away, and RemoveWorker returns once the jobs they are processing are done.
+func (m *Manager) RemoveWorker(queue string) error {
+	m.lock.Lock()
+	var removed []*worker
+	var kept []*worker
+	for _, w := range m.workers {
+		if w.queue == queue {
+			removed = append(removed, w)
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	m.workers = kept
+
+	// Only the workers started in this run are waited for
+	var stopped []chan bool
+	for _, w := range removed {
+		if w.stopped != nil {
+			w.quit()
+			stopped = append(stopped, w.stopped)
+		}
+	}
+	m.lock.Unlock()
+
+	if len(removed) == 0 {
+		return ErrWorkerNotFound
+	}
+	for _, ch := range stopped {
+		<-ch
+	}
+	return nil
+}
+
+// startWorker starts a worker while the manager runs. The manager lock must be held.
+func (m *Manager) startWorker(w *worker) {
+	stopped := make(chan bool)
+	w.stopped = stopped
+
+	m.wg.Add(1)
+	go func() {
+		w.start(m.workerFetcher(w))
+		close(stopped)
+		m.wg.Done()
+	}()
 }
 
 // AddBeforeStartHooks adds functions to be executed before the manager starts
@@ -102,39 +205,66 @@ func (m *Manager) AddRetriesExhaustedHandlers(handlers ...RetriesExhaustedFunc)
 	m.retriesExhaustedHandlers = append(m.retriesExhaustedHandlers, handlers...)
 }
 
-// Run starts all workers under this Manager and blocks until they exit.
+// Run starts all workers under this Manager and blocks until they exit. Unlike
+// RunContext, it starts when the store can't be reached, and the workers retry
+// fetching until it can.
 func (m *Manager) Run() {
+	if err := m.run(context.Background(), false); err != nil {
+		m.logger.Error("manager stopped", LogKeyError, err)
+	}
+}
+
+// RunContext starts all workers under this Manager and blocks until they exit, after
+// ctx is done or Stop is called. It returns ErrManagerRunning when the manager already
+// runs, an error when the store can't be reached at startup, and the errors that
+// happened while stopping.
+func (m *Manager) RunContext(ctx context.Context) error {
+	return m.run(ctx, true)
+}
+
+// run starts the manager, failing when requireStore is set and the store can't be reached
+func (m *Manager) run(ctx context.Context, requireStore bool) error {
+	// Ping before locking, so that a slow store doesn't block the manager
+	pingErr := m.opts.store.Ping(ctx)
+
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	if m.running {
-		return // Can't start if we're already running!
+		return ErrManagerRunning
+	}
+	if pingErr != nil {
+		if requireStore {
+			return fmt.Errorf("couldn't reach the store: %w", pingErr)
+		}
+		m.logger.Error("couldn't reach the store", LogKeyError, pingErr)
 	}
 	m.running = true
+	m.stopping = false
+	m.quiet = false
 
 	for _, h := range m.beforeStartHooks {
 		h()
 	}
 
-	globalAPIServer.registerManager(m)
+	globalAPIServer.AddManager(m)
 
-	var wg sync.WaitGroup
+	wg := &m.wg
 
-	wg.Add(1)
-	m.signal = make(chan os.Signal, 1)
-	go func() {
-		m.handleSignals()
-		wg.Done()
-	}()
-
-	wg.Add(len(m.workers))
-	for i := range m.workers {
-		w := m.workers[i]
+	m.signal = nil
+	if !m.opts.DisableSignalHandling {
+		wg.Add(1)
+		m.signal = make(chan os.Signal, 1)
 		go func() {
-			w.start(newSimpleFetcher(w.queue, m.opts))
+			m.handleSignals()
 			wg.Done()
 		}()
 	}
+
+	for _, w := range m.workers {
+		m.startWorker(w)
+	}
 	m.schedule = newScheduledWorker(m.opts)
+	m.schedule.onError = m.schedulerError
 
 	wg.Add(1)
 	go func() {
@@ -142,30 +272,173 @@ func (m *Manager) Run() {
 		wg.Done()
 	}()
 
+	m.flusher = newJobMetricsFlusher(m.opts, m.jobMetrics)
+
+	var flushErr error
+	wg.Add(1)
+	go func() {
+		flushErr = m.flusher.run()
+		wg.Done()
+	}()
+
+	m.scaler = newConcurrencyScaler(m)
+
+	wg.Add(1)
+	go func() {
+		m.scaler.run()
+		wg.Done()
+	}()
+
+	stopped := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.Stop()
+		case <-stopped:
+		}
+	}()
+
 	// Release the lock so that Stop can acquire it
 	m.lock.Unlock()
 	wg.Wait()
+	close(stopped)
 	// Regain the lock
 	m.lock.Lock()
-	globalAPIServer.deregisterManager(m)
+	globalAPIServer.RemoveManager(m)
 	m.running = false
+	for _, w := range m.workers {
+		w.stopped = nil
+	}
+	m.opts.events.publish(EventStopped, "", nil, nil)
+
+	if flushErr != nil {
+		return fmt.Errorf("couldn't save job metrics: %w", flushErr)
+	}
+	return nil
 }
 
 // Stop all workers under this Manager and returns immediately.
 func (m *Manager) Stop() {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	if !m.running {
+	if !m.running || m.stopping {
 		return
 	}
+	m.stopping = true
+	if !m.quiet {
+		m.opts.events.publish(EventQuiet, "", nil, nil)
+		m.schedule.quit()
+	}
 	for _, w := range m.workers {
-		w.quit()
+		// Workers added while the manager was quiet weren't started
+		if w.stopped != nil {
+			w.quit()
+		}
 	}
-	m.schedule.quit()
+	m.flusher.quit()
+	m.scaler.quit()
 	for _, h := range m.duringDrainHooks {
 		h()
 	}
-	m.stopSignalHandler()
+	if m.signal != nil {
+		m.stopSignalHandler()
+	}
+}
+
+// Quiet stops fetching new jobs and enqueuing scheduled jobs, while the jobs being
+// processed finish. The manager keeps running until Stop is called.
+func (m *Manager) Quiet() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.running || m.stopping || m.quiet {
+		return
+	}
+	m.quiet = true
+	m.opts.events.publish(EventQuiet, "", nil, nil)
+	for _, w := range m.workers {
+		if w.stopped != nil {
+			w.quiet()
+		}
+	}
+	m.schedule.quit()
+}
+
+// IsQuiet returns whether the manager runs without fetching new jobs. The quiet state
+// is only reported by the manager's stats and health, since there's no process heartbeat.
+func (m *Manager) IsQuiet() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.running && m.quiet
+}
+
+// workerFetcher creates the fetcher of a worker, with its factory when it has one
+func (m *Manager) workerFetcher(w *worker) Fetcher {
+	if w.newFetcher == nil {
+		return m.newFetcher(w.queue)
+	}
+
+	return w.newFetcher(FetcherConfig{
+		Queue:     w.queue,
+		Namespace: m.opts.Namespace,
+		ProcessID: m.opts.ProcessID,
+		Store:     m.opts.store,
+		Logger:    m.logger,
+		OnError:   func(err error) { m.fetchError(w.queue, err) },
+	})
+}
+
+func (m *Manager) newFetcher(queue string) Fetcher {
+	if m.opts.LeaseFetch {
+		f := newLeaseFetcher(queue, m.opts)
+		f.onError = m.fetchError
+		f.onInvalidMessage = m.invalidMessage
+		return f
+	}
+
+	if m.opts.FetchBatchSize > 1 {
+		f := newBatchFetcher(queue, m.opts)
+		f.onError = m.fetchError
+		f.onInvalidMessage = m.invalidMessage
+		return f
+	}
+
+	f := newSimpleFetcher(queue, m.opts)
+	f.onError = m.fetchError
+	f.onInvalidMessage = m.invalidMessage
+	return f
+}
+
+// fetchError records an error a fetcher got while reading from the store
+func (m *Manager) fetchError(queue string, err error) {
+	m.fetchErrorsLock.Lock()
+	defer m.fetchErrorsLock.Unlock()
+	if m.fetchErrors == nil {
+		m.fetchErrors = map[string]int64{}
+	}
+	m.fetchErrors[queue]++
+	m.opts.events.publish(EventFetchError, queue, nil, err)
+	m.reportError(err, ErrorContext{Source: ErrorSourceFetch, Queue: queue})
+}
+
+// invalidMessage reports a fetched payload that couldn't be parsed
+func (m *Manager) invalidMessage(queue string, message string, err error) {
+	m.reportError(err, ErrorContext{Source: ErrorSourceMessage, Queue: queue, RawMessage: message})
+}
+
+// schedulerError reports an error the scheduler got while enqueueing a scheduled or retried job
+func (m *Manager) schedulerError(message string, err error) {
+	m.reportError(err, ErrorContext{Source: ErrorSourceScheduler, RawMessage: message})
+}
+
+// fetchErrorCounts returns the number of fetch errors per queue since the manager was created
+func (m *Manager) fetchErrorCounts() map[string]int64 {
+	m.fetchErrorsLock.Lock()
+	defer m.fetchErrorsLock.Unlock()
+	counts := map[string]int64{}
+	for queue, count := range m.fetchErrors {
+		counts[queue] = count
+	}
+	return counts
 }
 
 func (m *Manager) inProgressMessages() map[string][]*Msg {
@@ -186,9 +459,12 @@ func (m *Manager) Producer() *Producer {
 // GetStats returns the set of stats for the manager
 func (m *Manager) GetStats() (Stats, error) {
 	stats := Stats{
-		Jobs:     map[string][]JobStatus{},
-		Enqueued: map[string]int64{},
-		Name:     m.opts.ManagerDisplayName,
+		Jobs:             map[string][]JobStatus{},
+		Enqueued:         map[string]int64{},
+		PriorityEnqueued: map[string]int64{},
+		Concurrency:      map[string]int{},
+		Name:             m.opts.ManagerDisplayName,
+		Quiet:            m.IsQuiet(),
 	}
 	var q []string
 
@@ -207,6 +483,10 @@ func (m *Manager) GetStats() (Stats, error) {
 		q = append(q, queue)
 	}
 
+	for queue, concurrency := range m.concurrency() {
+		stats.Concurrency[ns+queue] = concurrency
+	}
+
 	storeStats, err := m.opts.store.GetAllStats(context.Background(), q)
 
 	if err != nil {
@@ -216,36 +496,45 @@ func (m *Manager) GetStats() (Stats, error) {
 	stats.Processed = storeStats.Processed
 	stats.Failed = storeStats.Failed
 	stats.RetryCount = storeStats.RetryCount
+	stats.Quarantined = storeStats.Quarantined
+
+	stats.JobMetrics, err = m.GetJobMetrics()
+	if err != nil {
+		return stats, err
+	}
 
-	for q, l := range stats.Enqueued {
+	for q, l := range storeStats.Enqueued {
 		stats.Enqueued[q] = l
 	}
+	for q, l := range storeStats.PriorityEnqueued {
+		stats.PriorityEnqueued[q] = l
+	}
 
 	return stats, nil
 }
 
-// GetRetries returns the set of retry jobs for the manager
-func (m *Manager) GetRetries(page uint64, pageSize int64, match string) (Retries, error) {
-	// TODO: add back pagination and filtering
+// GetStatsHistory returns the number of processed and failed jobs for each of the last days, including today
+func (m *Manager) GetStatsHistory(days int) (StatsHistory, error) {
+	if days > maxStatsHistoryDays {
+		days = maxStatsHistoryDays
+	}
 
-	storeRetries, err := m.opts.store.GetAllRetries(context.Background())
-	if err != nil {
-		return Retries{}, err
+	history := StatsHistory{
+		Name:      m.opts.ManagerDisplayName,
+		Processed: map[string]int64{},
+		Failed:    map[string]int64{},
 	}
 
-	var retryJobs []*Msg
-	for _, r := range storeRetries.RetryJobs {
-		// parse json from string of retry data
-		retryJob, err := NewMsg(r)
-		if err != nil {
-			return Retries{}, err
-		}
+	daily, err := m.opts.store.GetStatsHistory(context.Background(), days, time.Now())
+	if err != nil {
+		return history, err
+	}
 
-		retryJobs = append(retryJobs, retryJob)
+	for _, d := range daily {
+		date := d.Date.Format(storage.StatsDateFormat)
+		history.Processed[date] = d.Processed
+		history.Failed[date] = d.Failed
 	}
 
-	return Retries{
-		TotalRetryCount: storeRetries.TotalRetryCount,
-		RetryJobs:       retryJobs,
-	}, nil
+	return history, nil
 }
@@ -1,14 +1,20 @@
 package workers
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/pioneerworks/go-sidekiq/storage"
 	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
 )
 
 func newTestManager(opts Options) (*Manager, error) {
@@ -198,8 +204,8 @@ func TestManager_Run(t *testing.T) {
 	mgr.AddWorker("queue2", 2, q2cc.F, NopMiddleware)
 
 	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		mgr.Run()
 		wg.Done()
 	}()
@@ -228,8 +234,8 @@ func TestManager_Run(t *testing.T) {
 	assert.NotContains(t, globalAPIServer.managers, mgr.uuid)
 
 	// Test that we can restart the manager
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		mgr.Run()
 		wg.Done()
 	}()
@@ -265,8 +271,8 @@ func TestManager_inProgressMessages(t *testing.T) {
 	mgr.AddWorker("queue2", 2, q2cc.F, NopMiddleware)
 
 	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		mgr.Run()
 		wg.Done()
 	}()
@@ -337,3 +343,411 @@ func TestManager_inProgressMessages(t *testing.T) {
 	mgr.Stop()
 	wg.Wait()
 }
+
+func TestManager_GetStatsHistory(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	rc := opts.client
+
+	today := time.Now().UTC()
+	yesterday := today.AddDate(0, 0, -1)
+	rc.Set(ctx, "prod:stat:processed:"+today.Format("2006-01-02"), 5, 0)
+	rc.Set(ctx, "prod:stat:failed:"+today.Format("2006-01-02"), 2, 0)
+	rc.Set(ctx, "prod:stat:processed:"+yesterday.Format("2006-01-02"), 7, 0)
+	rc.Set(ctx, "prod:stat:processed:"+today.AddDate(0, 0, -3).Format("2006-01-02"), 9, 0)
+
+	history, err := mgr.GetStatsHistory(3)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		today.Format("2006-01-02"):                   5,
+		yesterday.Format("2006-01-02"):               7,
+		today.AddDate(0, 0, -2).Format("2006-01-02"): 0,
+	}, history.Processed)
+	assert.Equal(t, map[string]int64{
+		today.Format("2006-01-02"):                   2,
+		yesterday.Format("2006-01-02"):               0,
+		today.AddDate(0, 0, -2).Format("2006-01-02"): 0,
+	}, history.Failed)
+}
+
+func TestManager_BoltStore(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sidekiq.db"), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	store, err := storage.NewBoltStore(db, "prod:")
+	assert.NoError(t, err)
+
+	mgr, err := NewManager(Options{
+		ProcessID:    "1",
+		Namespace:    "prod",
+		PollInterval: time.Second,
+		Store:        store,
+	})
+	assert.NoError(t, err)
+
+	// The warning about the nil client is only logged once
+	var logs bytes.Buffer
+	mgr.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	assert.Nil(t, mgr.GetRedisClient())
+	assert.Nil(t, mgr.GetRedisClient())
+	assert.Equal(t, 1, strings.Count(logs.String(), "GetRedisClient returns nil"))
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	prod := mgr.Producer()
+	_, err = prod.Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+	cc.ackSyncCh <- true
+
+	_, err = prod.EnqueueIn("queue1", "any", 1, cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+	cc.ackSyncCh <- true
+
+	mgr.Stop()
+	wg.Wait()
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Processed)
+
+	queues, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Len(t, queues, 1)
+}
+
+func TestManager_AddWorkerWhileRunning(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue2", "any", []int{1})
+	assert.NoError(t, err)
+
+	done := make(chan bool)
+	mgr.AddWorker("queue2", 1, func(m *Msg) error {
+		close(done)
+		return nil
+	})
+	<-done
+
+	mgr.Stop()
+	wg.Wait()
+}
+
+func TestManager_RemoveWorker(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+	mgr.AddWorker("queue2", 1, newCallCounter().F)
+
+	assert.ErrorIs(t, mgr.RemoveWorker("queue3"), ErrWorkerNotFound)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	removed := make(chan error)
+	go func() {
+		removed <- mgr.RemoveWorker("queue1")
+	}()
+
+	// The removed worker finishes its job first
+	select {
+	case <-removed:
+		t.Fatal("worker removed before its job was done")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cc.ackSyncCh <- true
+	assert.NoError(t, <-removed)
+
+	mgr.lock.Lock()
+	assert.Len(t, mgr.workers, 1)
+	assert.Equal(t, "queue2", mgr.workers[0].queue)
+	mgr.lock.Unlock()
+
+	size, err := mgr.opts.store.QueueSize(context.Background(), "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	mgr.Stop()
+	wg.Wait()
+	assert.Equal(t, 1, cc.count)
+}
+
+func TestManager_RemoveWorkerWhileStopping(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	// The manager keeps running until the job is done, and doesn't start new workers
+	mgr.Stop()
+	mgr.AddWorker("queue2", 1, newCallCounter().F)
+
+	removed := make(chan error)
+	go func() {
+		removed <- mgr.RemoveWorker("queue2")
+	}()
+	select {
+	case err := <-removed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker that wasn't started couldn't be removed")
+	}
+
+	cc.ackSyncCh <- true
+	wg.Wait()
+}
+
+func TestManager_Quiet(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+	s := mgr.Subscribe(10, EventQuiet, EventStopped)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		mgr.Run()
+		wg.Done()
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	mgr.Quiet()
+	assert.True(t, mgr.IsQuiet())
+	assert.Equal(t, EventQuiet, (<-s.Events()).Type)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.True(t, stats.Quiet)
+
+	// The job being processed finishes, and new jobs aren't fetched
+	_, err = mgr.Producer().Enqueue("queue1", "any", []int{1})
+	assert.NoError(t, err)
+	cc.ackSyncCh <- true
+
+	assert.Eventually(t, func() bool {
+		size, err := mgr.opts.store.QueueSize(context.Background(), "queue1:1:inprogress")
+		return err == nil && size == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	size, err := mgr.opts.store.QueueSize(context.Background(), "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	mgr.Stop()
+	wg.Wait()
+
+	assert.False(t, mgr.IsQuiet())
+	assert.Equal(t, EventStopped, (<-s.Events()).Type)
+	assert.Equal(t, 1, cc.count)
+}
+
+func TestManager_AddWorkerWhileQuiet(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	opts.DisableSignalHandling = true
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	result := make(chan error)
+	go func() {
+		result <- mgr.RunContext(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		mgr.lock.Lock()
+		defer mgr.lock.Unlock()
+		return mgr.running
+	}, time.Second, 10*time.Millisecond)
+
+	// Workers added while quiet aren't started, and can be removed
+	mgr.Quiet()
+	mgr.AddWorker("queue2", 1, newCallCounter().F)
+	removed := make(chan error)
+	go func() {
+		removed <- mgr.RemoveWorker("queue2")
+	}()
+	select {
+	case err := <-removed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker that wasn't started couldn't be removed")
+	}
+
+	done := make(chan bool)
+	mgr.AddWorker("queue3", 1, func(m *Msg) error {
+		close(done)
+		return nil
+	})
+	mgr.Stop()
+	assert.NoError(t, <-result)
+
+	// They start with the next run
+	go func() {
+		result <- mgr.RunContext(context.Background())
+	}()
+	_, err = mgr.Producer().Enqueue("queue3", "any", []int{1})
+	assert.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker added while quiet didn't start with the next run")
+	}
+
+	mgr.Stop()
+	assert.NoError(t, <-result)
+}
+
+func TestManager_RunContext(t *testing.T) {
+	opts := testOptionsWithNamespace("prod")
+	opts.PollInterval = time.Second
+	opts.DisableSignalHandling = true
+	mgr, err := newTestManager(opts)
+	assert.NoError(t, err)
+
+	cc := newCallCounter()
+	mgr.AddWorker("queue1", 1, cc.F)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- mgr.RunContext(ctx)
+	}()
+
+	_, err = mgr.Producer().Enqueue("queue1", "any", cc.syncMsg().Args().Interface())
+	assert.NoError(t, err)
+	<-cc.syncCh
+
+	assert.ErrorIs(t, mgr.RunContext(context.Background()), ErrManagerRunning)
+	mgr.lock.Lock()
+	assert.Nil(t, mgr.signal)
+	mgr.lock.Unlock()
+
+	// The job being processed finishes once the context is cancelled
+	cancel()
+	select {
+	case <-result:
+		t.Fatal("manager stopped before its job was done")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cc.ackSyncCh <- true
+	assert.NoError(t, <-result)
+	assert.Equal(t, 1, cc.count)
+}
+
+func TestManager_RunContextStoreError(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sidekiq.db"), 0600, nil)
+	assert.NoError(t, err)
+
+	store, err := storage.NewBoltStore(db, "prod:")
+	assert.NoError(t, err)
+
+	mgr, err := NewManager(Options{
+		ProcessID: "1",
+		Namespace: "prod",
+		Store:     store,
+	})
+	assert.NoError(t, err)
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	assert.NoError(t, db.Close())
+	err = mgr.RunContext(context.Background())
+	assert.ErrorIs(t, err, bolt.ErrDatabaseNotOpen)
+	assert.False(t, mgr.running)
+}
+
+func TestManager_RunStoreError(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sidekiq.db"), 0600, nil)
+	assert.NoError(t, err)
+
+	store, err := storage.NewBoltStore(db, "prod:")
+	assert.NoError(t, err)
+
+	mgr, err := NewManager(Options{
+		ProcessID:             "1",
+		Namespace:             "prod",
+		Store:                 store,
+		PollInterval:          time.Second,
+		DisableSignalHandling: true,
+	})
+	assert.NoError(t, err)
+	mgr.AddWorker("queue1", 1, newCallCounter().F)
+
+	// Run keeps running until it's stopped, while the workers retry fetching
+	assert.NoError(t, db.Close())
+	done := make(chan bool)
+	go func() {
+		mgr.Run()
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		mgr.lock.Lock()
+		defer mgr.lock.Unlock()
+		return mgr.running
+	}, time.Second, 10*time.Millisecond)
+
+	mgr.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("manager didn't stop")
+	}
+}
@@ -1,20 +1,23 @@
 package workers
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"runtime"
+	"log/slog"
 	"time"
 )
 
-// LogMiddleware is the default logging middleware
+// LogMiddleware is the default logging middleware. Job starts and arguments
+// are logged at debug level, completions at info level and failures at error level.
 func LogMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 	return func(message *Msg) (err error) {
-		prefix := fmt.Sprint(queue, " JID-", message.Jid())
+		logger := mgr.logger.With(messageLogAttrs(queue, message)...)
 
 		start := time.Now()
-		mgr.logger.Println(prefix, "start")
-		mgr.logger.Println(prefix, "args:", message.Args().ToJson())
+		if logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("start")
+			logger.Debug("args", "args", message.Args().ToJson())
+		}
 
 		defer func() {
 			if e := recover(); e != nil {
@@ -24,7 +27,7 @@ func LogMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 				}
 
 				if err != nil {
-					logProcessError(mgr.logger, prefix, start, err)
+					logProcessError(logger, start, err)
 				}
 			}
 
@@ -32,9 +35,9 @@ func LogMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 
 		err = next(message)
 		if err != nil {
-			logProcessError(mgr.logger, prefix, start, err)
+			logProcessError(logger, start, err)
 		} else {
-			mgr.logger.Println(prefix, "done:", time.Since(start))
+			logger.Info("done", LogKeyDuration, time.Since(start))
 		}
 
 		return
@@ -42,10 +45,6 @@ func LogMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 
 }
 
-func logProcessError(logger *log.Logger, prefix string, start time.Time, err error) {
-	logger.Println(prefix, "fail:", time.Since(start))
-
-	buf := make([]byte, 4096)
-	buf = buf[:runtime.Stack(buf, false)]
-	logger.Printf("%s error: %v\n%s", prefix, err, buf)
+func logProcessError(logger *slog.Logger, start time.Time, err error) {
+	logger.Error("fail", LogKeyDuration, time.Since(start), LogKeyError, err, "stack", string(callStack()))
 }
@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogMiddleware(t *testing.T) {
+	message, _ := NewMsg(`{"jid":"2","class":"Add","args":[1,2],"retry_count":3}`)
+
+	t.Run("info level", func(t *testing.T) {
+		var buf bytes.Buffer
+		mgr := &Manager{logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+		err := LogMiddleware("myqueue", mgr, func(m *Msg) error { return nil })(message)
+		assert.NoError(t, err)
+
+		records := logRecords(t, &buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "INFO", records[0]["level"])
+			assert.Equal(t, "done", records[0]["msg"])
+			assert.Equal(t, "myqueue", records[0][LogKeyQueue])
+			assert.Equal(t, "Add", records[0][LogKeyClass])
+			assert.Equal(t, "2", records[0][LogKeyJid])
+			assert.Equal(t, float64(3), records[0][LogKeyRetryCount])
+			assert.Contains(t, records[0], LogKeyDuration)
+		}
+	})
+
+	t.Run("debug level", func(t *testing.T) {
+		var buf bytes.Buffer
+		mgr := &Manager{logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+
+		err := LogMiddleware("myqueue", mgr, func(m *Msg) error { return nil })(message)
+		assert.NoError(t, err)
+
+		records := logRecords(t, &buf)
+		if assert.Len(t, records, 3) {
+			assert.Equal(t, "start", records[0]["msg"])
+			assert.Equal(t, "args", records[1]["msg"])
+			assert.Equal(t, "[1,2]", records[1]["args"])
+			assert.Equal(t, "done", records[2]["msg"])
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		var buf bytes.Buffer
+		mgr := &Manager{logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError}))}
+
+		err := LogMiddleware("myqueue", mgr, func(m *Msg) error { return errors.New("boom") })(message)
+		assert.EqualError(t, err, "boom")
+
+		records := logRecords(t, &buf)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, "ERROR", records[0]["level"])
+			assert.Equal(t, "fail", records[0]["msg"])
+			assert.Equal(t, "boom", records[0][LogKeyError])
+			assert.Equal(t, "2", records[0][LogKeyJid])
+			assert.NotEmpty(t, records[0]["stack"])
+		}
+	})
+}
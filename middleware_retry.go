@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"strings"
 	"time"
 )
 
@@ -41,8 +42,11 @@ func retryProcessError(queue string, mgr *Manager, message *Msg, err error) erro
 		// it'll disappear into the void.
 		if err != nil {
 			message.ack = false
+		} else {
+			mgr.opts.events.publish(EventRetried, strings.TrimPrefix(queue, mgr.opts.Namespace), message, nil)
 		}
 	} else {
+		mgr.opts.events.publish(EventRetriesExhausted, strings.TrimPrefix(queue, mgr.opts.Namespace), message, err)
 		for _, retriesExhaustedHandler := range mgr.retriesExhaustedHandlers {
 			retriesExhaustedHandler(queue, message, err)
 		}
@@ -241,6 +241,32 @@ func TestRetryMaxCallsRetryExhaustionHandler(t *testing.T) {
 	assert.NotNil(t, resultMessage.Args())
 }
 
+func TestRetryEvents(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.events = newEventBus()
+
+	mgr := &Manager{opts: opts}
+	s := mgr.Subscribe(10, EventRetried, EventRetriesExhausted)
+	defer s.Close()
+
+	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+	wares.build("prod:myqueue", mgr, panickingFunc)(message)
+
+	e := nextEvent(t, s)
+	assert.Equal(t, EventRetried, e.Type)
+	assert.Equal(t, "myqueue", e.Queue)
+	assert.Equal(t, "2", e.Message.Jid())
+
+	message, _ = NewMsg("{\"jid\":\"3\",\"retry\":true,\"retry_count\":25}")
+	wares.build("prod:myqueue", mgr, panickingFunc)(message)
+
+	e = nextEvent(t, s)
+	assert.Equal(t, EventRetriesExhausted, e.Type)
+	assert.Equal(t, "3", e.Message.Jid())
+	assert.EqualError(t, e.Err, errorText)
+}
+
 func TestRetryOnlyToCustomMax(t *testing.T) {
 	ctx := context.Background()
 
@@ -3,11 +3,18 @@ package workers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // StatsMiddleware middleware to collect stats on processed messages
 func StatsMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
+	metricsQueue := strings.TrimPrefix(queue, mgr.opts.Namespace)
+
 	return func(message *Msg) (err error) {
+		start := time.Now()
+		queueTime := messageQueueTime(message, start)
+
 		defer func() {
 			if e := recover(); e != nil {
 				var ok bool
@@ -17,6 +24,7 @@ func StatsMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 
 				if err != nil {
 					incrementStats(mgr, "failed")
+					mgr.jobMetrics.observe(metricsQueue, message.Class(), true, time.Since(start), queueTime)
 				}
 			}
 
@@ -28,6 +36,7 @@ func StatsMiddleware(queue string, mgr *Manager, next JobFunc) JobFunc {
 		} else {
 			incrementStats(mgr, "processed")
 		}
+		mgr.jobMetrics.observe(metricsQueue, message.Class(), err != nil, time.Since(start), queueTime)
 
 		return
 	}
@@ -37,6 +46,17 @@ func incrementStats(mgr *Manager, metric string) {
 	err := mgr.opts.store.IncrementStats(context.Background(), metric)
 
 	if err != nil {
-		mgr.logger.Println("couldn't save stats:", err)
+		mgr.logger.Error("couldn't save stats", LogKeyError, err)
 	}
 }
+
+// messageQueueTime returns how long a message waited in its queue before the
+// given time, or a negative duration when the message has no enqueued_at
+func messageQueueTime(message *Msg, now time.Time) time.Duration {
+	enqueuedAt, err := message.Get("enqueued_at").Float64()
+	if err != nil {
+		return -1
+	}
+
+	return now.Sub(time.Unix(0, int64(enqueuedAt*NanoSecondPrecision)))
+}
@@ -82,3 +82,27 @@ func TestFailedStats(t *testing.T) {
 	dayCountInt, _ = strconv.ParseInt(dayCount, 10, 64)
 	assert.Equal(t, int64(1), dayCountInt)
 }
+
+func TestStatsHistoryTTL(t *testing.T) {
+	ctx := context.Background()
+	options := testOptionsWithNamespace("prod")
+	options.StatsHistoryTTL = time.Hour
+	opts, err := processOptions(options)
+	assert.NoError(t, err)
+	opts.client.FlushDB(ctx)
+	mgr := &Manager{opts: opts}
+	rc := opts.client
+
+	message, _ := NewMsg("{\"jid\":\"2\",\"retry\":true}")
+	NewMiddlewares(StatsMiddleware).build("myqueue", mgr, func(m *Msg) error {
+		return nil
+	})(message)
+
+	ttl, err := rc.TTL(ctx, "prod:stat:processed:"+time.Now().UTC().Format("2006-01-02")).Result()
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute))
+
+	ttl, err = rc.TTL(ctx, "prod:stat:processed").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+}
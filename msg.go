@@ -1,8 +1,8 @@
 package workers
 
 import (
-	"log"
-	"os"
+	"context"
+	"log/slog"
 	"reflect"
 
 	"github.com/bitly/go-simplejson"
@@ -10,7 +10,7 @@ import (
 
 // Logger is the default go-sidekiq logger, only used here in this file.
 // TODO: remove this
-var Logger = log.New(os.Stdout, "go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+var Logger = NewLogger(slog.LevelInfo, false)
 
 type data struct {
 	*simplejson.Json
@@ -22,6 +22,7 @@ type Msg struct {
 	original  string
 	ack       bool
 	startedAt int64
+	ctx       context.Context
 }
 
 // Args is the set of parameters for a message
@@ -39,6 +40,11 @@ func (m *Msg) Jid() string {
 	return m.Get("jid").MustString()
 }
 
+// Priority returns the priority attribute of a message, or 0 when it has none
+func (m *Msg) Priority() float64 {
+	return m.Get("priority").MustFloat64()
+}
+
 // Args returns arguments attribute of a message
 func (m *Msg) Args() *Args {
 	if args, ok := m.CheckGet("args"); ok {
@@ -49,6 +55,15 @@ func (m *Msg) Args() *Args {
 	return &Args{d}
 }
 
+// Context returns the context of the message, which carries the span started
+// by TracingMiddleware. It defaults to the background context.
+func (m *Msg) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
 // OriginalJson returns the original JSON message
 func (m *Msg) OriginalJson() string {
 	return m.original
@@ -59,7 +74,7 @@ func (d *data) ToJson() string {
 	json, err := d.Encode()
 
 	if err != nil {
-		Logger.Println("ERR: Couldn't generate json from", d, ":", err)
+		Logger.Error("couldn't generate json", "data", d, LogKeyError, err)
 	}
 
 	return string(json)
@@ -84,6 +99,22 @@ func NewMsg(content string) (*Msg, error) {
 	}, nil
 }
 
+// snapshot returns a copy of the message that doesn't share its data, so it can be
+// read from another goroutine while the job keeps changing the message
+func (m *Msg) snapshot() *Msg {
+	d, err := newData(m.ToJson())
+	if err != nil {
+		d, _ = newData(m.original)
+	}
+	return &Msg{
+		data:      d,
+		original:  m.original,
+		ack:       m.ack,
+		startedAt: m.startedAt,
+		ctx:       m.ctx,
+	}
+}
+
 func newData(content string) (*data, error) {
 	json, err := simplejson.NewJson([]byte(content))
 	if err != nil {
@@ -3,8 +3,8 @@ package workers
 import (
 	"crypto/tls"
 	"errors"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -21,20 +21,81 @@ type Options struct {
 	Password     string
 	PoolSize     int
 
-	// Provide one of ServerAddr or (SentinelAddrs + RedisMasterName)
+	// Provide one of ServerAddr, (SentinelAddrs + RedisMasterName) or ClusterAddrs.
+	// With ClusterAddrs all the keys of the namespace are in one slot, see RedisHashTag.
 	ServerAddr      string
 	SentinelAddrs   string
 	RedisMasterName string
+	ClusterAddrs    string
 	RedisTLSConfig  *tls.Config
 
+	// Wrap the namespace in a hash tag, e.g. "{myapp}:", so that Redis Cluster stores all keys
+	// in the same slot. Always enabled for cluster clients; the namespace defaults to
+	// DefaultHashTagNamespace when it is empty.
+	//
+	// This doesn't shard a namespace: a single node stores and serves the slot, so all the
+	// load of a namespace goes to that node and the cluster only provides failover. Use
+	// several namespaces to spread queues over several nodes.
+	RedisHashTag bool
+
 	// Optional display name used when displaying manager stats
 	ManagerDisplayName string
 
-	// Log
-	Logger *log.Logger
+	// Optional duration after which daily stats are expired. Daily stats are kept forever by default.
+	StatsHistoryTTL time.Duration
+
+	// How often per queue and per class job metrics are saved, defaults to 10 seconds
+	MetricsFlushInterval time.Duration
+
+	// How often the concurrency policies set with Manager.SetConcurrencyPolicy are evaluated, defaults to 10 seconds
+	ConcurrencyPolicyInterval time.Duration
+
+	// Structured logger, defaults to a text logger writing to stdout at LogLevel
+	Logger *slog.Logger
+
+	// Minimum level of the default logger, defaults to info. Job starts and arguments are logged at debug level.
+	LogLevel slog.Leveler
+
+	// Use JSON output for the default logger
+	LogJSON bool
+
+	// Fetch jobs by taking a lease on them instead of moving them to an in progress list per ProcessID.
+	// Leases are extended while jobs run, and the jobs of managers that went away are fetched again by
+	// any manager once their lease expired, so the ProcessID doesn't need to be stable across restarts.
+	LeaseFetch bool
+
+	// Duration of the leases taken when LeaseFetch is set, defaults to DefaultLeaseDuration. It can't be under MinLeaseDuration.
+	LeaseDuration time.Duration
+
+	// Fetch up to FetchBatchSize jobs at once and buffer them for the workers that are ready, instead of
+	// fetching jobs one at a time. Buffered jobs are moved back to their queue when the manager stops.
+	// Ignored when LeaseFetch is set.
+	FetchBatchSize int
+
+	// Fetch the jobs enqueued with a priority before the other jobs of their queue, highest priority
+	// first. Can't be combined with LeaseFetch or FetchBatchSize. Producers need it to enqueue
+	// jobs with a priority.
+	PriorityFetch bool
+
+	// Time after which a fetch that didn't return, or a scheduler that didn't poll (in addition to
+	// PollInterval), makes the manager unhealthy, defaults to one minute
+	HealthTimeout time.Duration
+
+	// Don't stop the manager on INT, TERM and USR1 signals, or quiet it on TSTP, for example when
+	// the manager is stopped by cancelling the context given to RunContext
+	DisableSignalHandling bool
+
+	// Optional functions called with new work before it is enqueued
+	EnqueueHooks []EnqueueHookFunc
 
-	client *redis.Client
+	// Optional store used instead of Redis, such as storage.NewBoltStore. The Redis
+	// options are ignored when it is set, and the store should be created with the
+	// same namespace, including its trailing colon.
+	Store storage.Store
+
+	client redis.UniversalClient
 	store  storage.Store
+	events *eventBus
 }
 
 func processOptions(options Options) (Options, error) {
@@ -43,6 +104,11 @@ func processOptions(options Options) (Options, error) {
 		return Options{}, err
 	}
 
+	if options.Store != nil {
+		options.store = options.Store
+		return options, nil
+	}
+
 	//redis options
 	if options.PoolSize == 0 {
 		options.PoolSize = 1
@@ -72,37 +138,41 @@ func processOptions(options Options) (Options, error) {
 			MasterName:    options.RedisMasterName,
 			TLSConfig:     options.RedisTLSConfig,
 		})
+	} else if options.ClusterAddrs != "" {
+		options.client = redis.NewClusterClient(&redis.ClusterOptions{
+			IdleTimeout: redisIdleTimeout,
+			Password:    options.Password,
+			PoolSize:    options.PoolSize,
+			Addrs:       strings.Split(options.ClusterAddrs, ","),
+			TLSConfig:   options.RedisTLSConfig,
+		})
 	} else {
-		return Options{}, errors.New("Options requires either the Server or Sentinels option")
-	}
-
-	if options.Logger == nil {
-		options.Logger = log.New(os.Stdout, "go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+		return Options{}, errors.New("Options requires either the Server, Sentinels or Cluster option")
 	}
 
-	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger)
+	options = useHashTag(options)
+	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger,
+		storage.WithStatsHistoryTTL(options.StatsHistoryTTL))
 	options.store = redisStore
 
 	return options, nil
 }
 
-func processOptionsWithRedisClient(options Options, client *redis.Client) (Options, error) {
+func processOptionsWithRedisClient(options Options, client redis.UniversalClient) (Options, error) {
 	options, err := validateGeneralOptions(options)
 	if err != nil {
 		return Options{}, err
 	}
 
-	if client == nil {
+	if isNilClient(client) {
 		return Options{}, errors.New("Redis client is nil; Redis client is not configured")
 	}
 
 	options.client = client
+	options = useHashTag(options)
 
-	if options.Logger == nil {
-		options.Logger = log.New(os.Stdout, "go-sidekiq ", log.Ldate|log.Lmicroseconds)
-	}
-
-	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger)
+	redisStore := storage.NewRedisStore(options.Namespace, options.client, options.Logger,
+		storage.WithStatsHistoryTTL(options.StatsHistoryTTL))
 	options.store = redisStore
 
 	return options, nil
@@ -121,5 +191,66 @@ func validateGeneralOptions(options Options) (Options, error) {
 		options.PollInterval = 15 * time.Second
 	}
 
+	if options.PriorityFetch && (options.LeaseFetch || options.FetchBatchSize > 1) {
+		return Options{}, errors.New("PriorityFetch can't be combined with LeaseFetch or FetchBatchSize")
+	}
+
+	if options.LeaseDuration > 0 && options.LeaseDuration < MinLeaseDuration {
+		return Options{}, fmt.Errorf("LeaseDuration must be at least %v", MinLeaseDuration)
+	}
+
+	if options.MetricsFlushInterval <= 0 {
+		options.MetricsFlushInterval = 10 * time.Second
+	}
+
+	if options.ConcurrencyPolicyInterval <= 0 {
+		options.ConcurrencyPolicyInterval = 10 * time.Second
+	}
+
+	if options.HealthTimeout <= 0 {
+		options.HealthTimeout = time.Minute
+	}
+
+	if options.Logger == nil {
+		level := options.LogLevel
+		if level == nil {
+			level = slog.LevelInfo
+		}
+		options.Logger = NewLogger(level, options.LogJSON)
+	}
+
 	return options, nil
 }
+
+// DefaultHashTagNamespace is the namespace used when hash tags are enabled without a namespace
+const DefaultHashTagNamespace = "sidekiq"
+
+// useHashTag wraps the namespace in a hash tag when required, so that every key
+// of a Redis Cluster deployment is stored in the same slot
+func useHashTag(options Options) Options {
+	if _, cluster := options.client.(*redis.ClusterClient); !cluster && !options.RedisHashTag {
+		return options
+	}
+
+	namespace := strings.TrimSuffix(options.Namespace, ":")
+	if namespace == "" {
+		namespace = DefaultHashTagNamespace
+	}
+	options.Namespace = "{" + namespace + "}:"
+
+	return options
+}
+
+func isNilClient(client redis.UniversalClient) bool {
+	switch c := client.(type) {
+	case nil:
+		return true
+	case *redis.Client:
+		return c == nil
+	case *redis.ClusterClient:
+		return c == nil
+	case *redis.Ring:
+		return c == nil
+	}
+	return false
+}
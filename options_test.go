@@ -2,10 +2,14 @@ package workers
 
 import (
 	"crypto/tls"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/pioneerworks/go-sidekiq/storage"
 	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestRedisPoolConfig(t *testing.T) {
@@ -16,7 +20,7 @@ func TestRedisPoolConfig(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.Equal(t, 1, opts.client.Options().PoolSize)
+	assert.Equal(t, 1, opts.client.(*redis.Client).Options().PoolSize)
 
 	opts, err = processOptions(Options{
 		ServerAddr: "localhost:6379",
@@ -25,7 +29,7 @@ func TestRedisPoolConfig(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.Equal(t, 20, opts.client.Options().PoolSize)
+	assert.Equal(t, 20, opts.client.(*redis.Client).Options().PoolSize)
 }
 
 func TestRedisPoolConfigTLS(t *testing.T) {
@@ -36,7 +40,7 @@ func TestRedisPoolConfigTLS(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.Nil(t, opts.client.Options().TLSConfig)
+	assert.Nil(t, opts.client.(*redis.Client).Options().TLSConfig)
 
 	opts, err = processOptions(Options{
 		ServerAddr:     "localhost:6379",
@@ -46,8 +50,8 @@ func TestRedisPoolConfigTLS(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.NotNil(t, opts.client.Options().TLSConfig)
-	assert.Equal(t, "test_tls", opts.client.Options().TLSConfig.ServerName)
+	assert.NotNil(t, opts.client.(*redis.Client).Options().TLSConfig)
+	assert.Equal(t, "test_tls", opts.client.(*redis.Client).Options().TLSConfig.ServerName)
 }
 
 func TestCustomProcessConfig(t *testing.T) {
@@ -127,8 +131,8 @@ func TestSentinelConfigGood(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.Equal(t, "FailoverClient", opts.client.Options().Addr)
-	assert.Nil(t, opts.client.Options().TLSConfig)
+	assert.Equal(t, "FailoverClient", opts.client.(*redis.Client).Options().Addr)
+	assert.Nil(t, opts.client.(*redis.Client).Options().TLSConfig)
 }
 
 func TestSentinelConfigGoodTLS(t *testing.T) {
@@ -141,9 +145,9 @@ func TestSentinelConfigGoodTLS(t *testing.T) {
 	})
 
 	assert.NoError(t, err)
-	assert.Equal(t, "FailoverClient", opts.client.Options().Addr)
-	assert.NotNil(t, opts.client.Options().TLSConfig)
-	assert.Equal(t, "test_tls", opts.client.Options().TLSConfig.ServerName)
+	assert.Equal(t, "FailoverClient", opts.client.(*redis.Client).Options().Addr)
+	assert.NotNil(t, opts.client.(*redis.Client).Options().TLSConfig)
+	assert.Equal(t, "test_tls", opts.client.(*redis.Client).Options().TLSConfig.ServerName)
 }
 
 func TestSentinelConfigNoMaster(t *testing.T) {
@@ -155,3 +159,87 @@ func TestSentinelConfigNoMaster(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestClusterConfig(t *testing.T) {
+	opts, err := processOptions(Options{
+		ClusterAddrs: "localhost:7000,localhost:7001",
+		ProcessID:    "1",
+		Namespace:    "prod",
+	})
+
+	assert.NoError(t, err)
+	client, ok := opts.client.(*redis.ClusterClient)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"localhost:7000", "localhost:7001"}, client.Options().Addrs)
+	}
+	assert.Equal(t, "{prod}:", opts.Namespace)
+}
+
+func TestHashTagConfig(t *testing.T) {
+	opts, err := processOptions(Options{
+		ServerAddr:   "localhost:6379",
+		ProcessID:    "1",
+		RedisHashTag: true,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "{"+DefaultHashTagNamespace+"}:", opts.Namespace)
+
+	_, err = processOptionsWithRedisClient(Options{ProcessID: "1"}, (*redis.ClusterClient)(nil))
+	assert.Error(t, err)
+}
+
+func TestStoreConfig(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sidekiq.db"), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	store, err := storage.NewBoltStore(db, "prod:")
+	assert.NoError(t, err)
+
+	opts, err := processOptions(Options{
+		ProcessID: "1",
+		Namespace: "prod",
+		Store:     store,
+	})
+
+	assert.NoError(t, err)
+	assert.Nil(t, opts.client)
+	assert.Equal(t, store, opts.store)
+	assert.Equal(t, "prod:", opts.Namespace)
+}
+
+func TestPriorityFetchConfig(t *testing.T) {
+	_, err := processOptions(Options{
+		ServerAddr:    "localhost:6379",
+		ProcessID:     "1",
+		PriorityFetch: true,
+	})
+	assert.NoError(t, err)
+
+	_, err = processOptions(Options{
+		ServerAddr:    "localhost:6379",
+		ProcessID:     "1",
+		PriorityFetch: true,
+		LeaseFetch:    true,
+	})
+	assert.Error(t, err)
+}
+
+func TestLeaseDurationConfig(t *testing.T) {
+	_, err := processOptions(Options{
+		ServerAddr:    "localhost:6379",
+		ProcessID:     "1",
+		LeaseFetch:    true,
+		LeaseDuration: MinLeaseDuration,
+	})
+	assert.NoError(t, err)
+
+	_, err = processOptions(Options{
+		ServerAddr:    "localhost:6379",
+		ProcessID:     "1",
+		LeaseFetch:    true,
+		LeaseDuration: 2 * time.Nanosecond,
+	})
+	assert.EqualError(t, err, "LeaseDuration must be at least 1s")
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -16,9 +18,16 @@ const (
 	NanoSecondPrecision = 1000000000.0
 )
 
+// ErrPriorityDisabled is returned when a job is enqueued with a priority by a producer
+// without the PriorityFetch option
+var ErrPriorityDisabled = errors.New("job priority requires the PriorityFetch option")
+
 // Producer is used to enqueue new work
 type Producer struct {
 	opts Options
+
+	// Warns once that GetRedisClient returns nil
+	redisClientWarning sync.Once
 }
 
 // EnqueueData stores data and configuration for new work
@@ -29,14 +38,27 @@ type EnqueueData struct {
 	Jid        string      `json:"jid"`
 	EnqueuedAt float64     `json:"enqueued_at"`
 	EnqueueOptions
+
+	// W3C trace context propagated to the job, see TracingEnqueueHook
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
 }
 
+// EnqueueHookFunc is called with new work before it is enqueued and may modify it.
+// Returning an error aborts the enqueue.
+type EnqueueHookFunc func(ctx context.Context, data *EnqueueData) error
+
 // EnqueueOptions stores configuration for new work
 type EnqueueOptions struct {
 	RetryCount int     `json:"retry_count,omitempty"`
 	RetryMax   int     `json:"retry_max,omitempty"`
 	Retry      bool    `json:"retry,omitempty"`
 	At         float64 `json:"at,omitempty"`
+
+	// Jobs with a priority are fetched before the other jobs of their queue, highest
+	// priority first, by managers with the PriorityFetch option. Other fetchers don't read
+	// them, so the producer must have the PriorityFetch option too.
+	Priority float64 `json:"priority,omitempty"`
 }
 
 // NewProducer creates a new producer with the given options
@@ -52,7 +74,7 @@ func NewProducer(options Options) (*Producer, error) {
 }
 
 // NewProducerWithRedisClient creates a new producer with the given options and Redis client
-func NewProducerWithRedisClient(options Options, client *redis.Client) (*Producer, error) {
+func NewProducerWithRedisClient(options Options, client redis.UniversalClient) (*Producer, error) {
 	options, err := processOptionsWithRedisClient(options, client)
 	if err != nil {
 		return nil, err
@@ -63,10 +85,17 @@ func NewProducerWithRedisClient(options Options, client *redis.Client) (*Produce
 	}, nil
 }
 
-// GetRedisClient returns the Redis client used by the producer
+// GetRedisClient returns the Redis client used by the producer, or nil when
+// it uses a cluster or another kind of universal client
 // Deprecated: the Redis client is an internal implementation and access will be removed
 func (p *Producer) GetRedisClient() *redis.Client {
-	return p.opts.client
+	client, ok := p.opts.client.(*redis.Client)
+	if !ok {
+		p.redisClientWarning.Do(func() {
+			p.opts.Logger.Warn("GetRedisClient returns nil for cluster clients and custom stores")
+		})
+	}
+	return client
 }
 
 // Enqueue enqueues new work for immediate processing
@@ -86,6 +115,17 @@ func (p *Producer) EnqueueAt(queue, class string, at time.Time, args interface{}
 
 // EnqueueWithOptions enqueues new work for processing with the given options
 func (p *Producer) EnqueueWithOptions(queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
+	return p.EnqueueWithOptionsContext(context.Background(), queue, class, args, opts)
+}
+
+// EnqueueContext enqueues new work for immediate processing. The context is passed to the enqueue hooks.
+func (p *Producer) EnqueueContext(ctx context.Context, queue, class string, args interface{}) (string, error) {
+	return p.EnqueueWithOptionsContext(ctx, queue, class, args, EnqueueOptions{At: nowToSecondsWithNanoPrecision()})
+}
+
+// EnqueueWithOptionsContext enqueues new work for processing with the given options.
+// The context is passed to the enqueue hooks.
+func (p *Producer) EnqueueWithOptionsContext(ctx context.Context, queue, class string, args interface{}, opts EnqueueOptions) (string, error) {
 	now := nowToSecondsWithNanoPrecision()
 	data := EnqueueData{
 		Queue:          queue,
@@ -96,29 +136,57 @@ func (p *Producer) EnqueueWithOptions(queue, class string, args interface{}, opt
 		EnqueueOptions: opts,
 	}
 
+	for _, hook := range p.opts.EnqueueHooks {
+		if err := hook(ctx, &data); err != nil {
+			return "", err
+		}
+	}
+
+	if data.Priority != 0 && !p.opts.PriorityFetch {
+		return "", ErrPriorityDisabled
+	}
+
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
 
-	if now < opts.At {
-		err = p.opts.store.EnqueueScheduledMessage(context.Background(), data.At, string(bytes))
+	if now < data.At {
+		err = p.opts.store.EnqueueScheduledMessage(ctx, data.At, string(bytes))
+		if err == nil {
+			p.enqueued(data.Queue, bytes)
+		}
 		return data.Jid, err
 	}
 
-	err = p.opts.store.CreateQueue(context.Background(), queue)
+	err = p.opts.store.CreateQueue(ctx, data.Queue)
 	if err != nil {
 		return "", err
 	}
 
-	err = p.opts.store.EnqueueMessageNow(context.Background(), queue, string(bytes))
+	if data.Priority != 0 {
+		err = p.opts.store.EnqueueMessage(ctx, data.Queue, data.Priority, string(bytes))
+	} else {
+		err = p.opts.store.EnqueueMessageNow(ctx, data.Queue, string(bytes))
+	}
 	if err != nil {
 		return "", err
 	}
+	p.enqueued(data.Queue, bytes)
 
 	return data.Jid, nil
 }
 
+// enqueued emits an enqueued event for the given message to the manager's subscribers
+func (p *Producer) enqueued(queue string, message []byte) {
+	if !p.opts.events.active() {
+		return
+	}
+	if msg, err := NewMsg(string(message)); err == nil {
+		p.opts.events.publish(EventEnqueued, queue, msg, nil)
+	}
+}
+
 func timeToSecondsWithNanoPrecision(t time.Time) float64 {
 	return float64(t.UnixNano()) / NanoSecondPrecision
 }
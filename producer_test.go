@@ -213,3 +213,42 @@ func TestNewProducerWithRedisClientNoProcessID(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, mgr)
 }
+
+func TestProducer_EnqueueWithPriority(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	// Jobs with a priority are only read by the priority fetcher
+	p := &Producer{opts: opts}
+	_, err = p.EnqueueWithOptions("priorityq", "Compare", []int{1}, EnqueueOptions{At: nowToSecondsWithNanoPrecision(), Priority: 5})
+	assert.ErrorIs(t, err, ErrPriorityDisabled)
+
+	opts.PriorityFetch = true
+	p = &Producer{opts: opts}
+
+	_, err = p.EnqueueWithOptions("priorityq", "Compare", []int{1}, EnqueueOptions{At: nowToSecondsWithNanoPrecision(), Priority: 5})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("priorityq", "Compare", []int{2})
+	assert.NoError(t, err)
+
+	// Jobs with a priority are added to the priority set of the queue
+	members, err := rc.ZRangeWithScores(ctx, "prod:queue:priorityq:priority", 0, -1).Result()
+	assert.NoError(t, err)
+	if assert.Len(t, members, 1) {
+		assert.Equal(t, 5.0, members[0].Score)
+		msg, err := NewMsg(members[0].Member.(string))
+		assert.NoError(t, err)
+		assert.Equal(t, 5.0, msg.Priority())
+	}
+
+	len, err := rc.LLen(ctx, "prod:queue:priorityq").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), len)
+
+	queues, err := rc.SMembers(ctx, "prod:queues").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"priorityq"}, queues)
+}
@@ -0,0 +1,215 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// promLabel is a single name/value pair of a Prometheus sample
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promSample struct {
+	suffix string
+	labels []promLabel
+	value  float64
+}
+
+type promFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []promSample
+}
+
+// promRegistry collects samples grouped by metric family, and writes them
+// using the Prometheus text exposition format
+type promRegistry struct {
+	families []*promFamily
+	byName   map[string]*promFamily
+}
+
+func newPromRegistry() *promRegistry {
+	return &promRegistry{byName: map[string]*promFamily{}}
+}
+
+func (r *promRegistry) family(name, kind, help string) *promFamily {
+	f, ok := r.byName[name]
+	if !ok {
+		f = &promFamily{name: name, kind: kind, help: help}
+		r.byName[name] = f
+		r.families = append(r.families, f)
+	}
+	return f
+}
+
+func (r *promRegistry) counter(name, help string, value float64, labels ...promLabel) {
+	f := r.family(name, "counter", help)
+	f.samples = append(f.samples, promSample{labels: labels, value: value})
+}
+
+func (r *promRegistry) gauge(name, help string, value float64, labels ...promLabel) {
+	f := r.family(name, "gauge", help)
+	f.samples = append(f.samples, promSample{labels: labels, value: value})
+}
+
+func (r *promRegistry) histogram(name, help string, h Histogram, labels ...promLabel) {
+	f := r.family(name, "histogram", help)
+	for _, b := range h.Buckets {
+		bucketLabels := append(append([]promLabel{}, labels...), promLabel{"le", formatPromValue(b.UpperBound)})
+		f.samples = append(f.samples, promSample{suffix: "_bucket", labels: bucketLabels, value: float64(b.Count)})
+	}
+	infLabels := append(append([]promLabel{}, labels...), promLabel{"le", "+Inf"})
+	f.samples = append(f.samples,
+		promSample{suffix: "_bucket", labels: infLabels, value: float64(h.Count)},
+		promSample{suffix: "_sum", labels: labels, value: h.Sum},
+		promSample{suffix: "_count", labels: labels, value: float64(h.Count)},
+	)
+}
+
+func (r *promRegistry) write(w io.Writer) error {
+	for _, f := range r.families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapePromHelp(f.help), f.name, f.kind); err != nil {
+			return err
+		}
+		for _, s := range f.samples {
+			if _, err := fmt.Fprintf(w, "%s%s%s %s\n", f.name, s.suffix, formatPromLabels(s.labels), formatPromValue(s.value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatPromLabels(labels []promLabel) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l.name + `="` + escapePromLabel(l.value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var (
+	promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	promHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapePromLabel(s string) string { return promLabelEscaper.Replace(s) }
+func escapePromHelp(s string) string  { return promHelpEscaper.Replace(s) }
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// collectStoreMetrics adds the metrics kept in the manager's store to the registry.
+// They're shared by all the managers of a namespace, so they are labelled by namespace
+// and should only be collected once per namespace
+func (m *Manager) collectStoreMetrics(r *promRegistry) error {
+	ctx := context.Background()
+
+	namespace := promLabel{"namespace", strings.TrimSuffix(m.opts.Namespace, ":")}
+
+	queues, err := m.GetQueues()
+	if err != nil {
+		return err
+	}
+
+	queueNames := make([]string, 0, len(queues))
+	for _, q := range queues {
+		queueNames = append(queueNames, q.Name)
+	}
+
+	storeStats, err := m.opts.store.GetAllStats(ctx, queueNames)
+	if err != nil {
+		return err
+	}
+
+	r.counter("sidekiq_jobs_processed_total", "Number of jobs processed successfully.", float64(storeStats.Processed), namespace)
+	r.counter("sidekiq_jobs_failed_total", "Number of jobs that failed.", float64(storeStats.Failed), namespace)
+
+	for _, q := range queues {
+		queue := promLabel{"queue", q.Name}
+		r.gauge("sidekiq_queue_size", "Number of jobs waiting in a queue.", float64(q.Size), namespace, queue)
+		r.gauge("sidekiq_queue_latency_seconds", "Time the oldest job of a queue has been waiting.", q.Latency, namespace, queue)
+		r.gauge("sidekiq_priority_queue_size", "Number of jobs enqueued with a priority in a queue.", float64(q.PrioritySize), namespace, queue)
+	}
+
+	for _, set := range []struct {
+		key  string
+		name string
+		help string
+	}{
+		{storage.RetryKey, "sidekiq_retry_set_size", "Number of jobs waiting to be retried."},
+		{storage.ScheduledJobsKey, "sidekiq_scheduled_set_size", "Number of jobs scheduled for later."},
+		{storage.DeadKey, "sidekiq_dead_set_size", "Number of jobs that won't be retried anymore."},
+		{storage.QuarantineKey, "sidekiq_quarantine_set_size", "Number of payloads that couldn't be parsed into jobs."},
+	} {
+		size, err := m.opts.store.SortedSetSize(ctx, set.key)
+		if err != nil {
+			return err
+		}
+		r.gauge(set.name, set.help, float64(size), namespace)
+	}
+
+	jobMetrics, err := m.GetJobMetrics()
+	if err != nil {
+		return err
+	}
+	for _, jm := range jobMetrics {
+		queue, class := promLabel{"queue", jm.Queue}, promLabel{"class", jm.Class}
+		r.histogram("sidekiq_job_duration_seconds", "Time spent executing jobs.", jm.ExecutionTime, namespace, queue, class)
+		r.histogram("sidekiq_job_queue_time_seconds", "Time jobs waited in their queue before executing.", jm.QueueTime, namespace, queue, class)
+	}
+
+	return nil
+}
+
+// collectMetrics adds the metrics local to the manager's process to the registry
+func (m *Manager) collectMetrics(r *promRegistry) {
+	name := m.opts.ManagerDisplayName
+	if name == "" {
+		name = m.opts.ProcessID
+	}
+	manager := promLabel{"manager", name}
+
+	inProgress := m.inProgressMessages()
+	busyQueues := make([]string, 0, len(inProgress))
+	for queue := range inProgress {
+		busyQueues = append(busyQueues, queue)
+	}
+	sort.Strings(busyQueues)
+	for _, queue := range busyQueues {
+		r.gauge("sidekiq_busy_runners", "Number of runners processing a job.", float64(len(inProgress[queue])), manager, promLabel{"queue", queue})
+	}
+
+	fetchErrors := m.fetchErrorCounts()
+	fetchQueues := make([]string, 0, len(fetchErrors))
+	for queue := range fetchErrors {
+		fetchQueues = append(fetchQueues, queue)
+	}
+	sort.Strings(fetchQueues)
+	for _, queue := range fetchQueues {
+		r.counter("sidekiq_fetch_errors_total", "Number of errors fetching jobs from a queue.", float64(fetchErrors[queue]), manager, promLabel{"queue", queue})
+	}
+}
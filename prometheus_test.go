@@ -0,0 +1,85 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromRegistry_Write(t *testing.T) {
+	r := newPromRegistry()
+	r.counter("jobs_total", "Number of jobs.", 3, promLabel{"manager", `a"b\c`})
+	r.counter("jobs_total", "Number of jobs.", 4, promLabel{"manager", "line\nbreak"})
+	r.gauge("size", "Size.", 1.5)
+	r.histogram("duration_seconds", "Durations.", Histogram{
+		Count:   3,
+		Sum:     0.75,
+		Buckets: []HistogramBucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 2}},
+	}, promLabel{"queue", "q"})
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.write(&buf))
+	assert.Equal(t, `# HELP jobs_total Number of jobs.
+# TYPE jobs_total counter
+jobs_total{manager="a\"b\\c"} 3
+jobs_total{manager="line\nbreak"} 4
+# HELP size Size.
+# TYPE size gauge
+size 1.5
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{queue="q",le="0.1"} 1
+duration_seconds_bucket{queue="q",le="1"} 2
+duration_seconds_bucket{queue="q",le="+Inf"} 3
+duration_seconds_sum{queue="q"} 0.75
+duration_seconds_count{queue="q"} 3
+`, buf.String())
+}
+
+func TestFormatPromValue(t *testing.T) {
+	assert.Equal(t, "+Inf", formatPromValue(math.Inf(1)))
+	assert.Equal(t, "NaN", formatPromValue(math.NaN()))
+	assert.Equal(t, "1e+06", formatPromValue(1000000))
+	assert.Equal(t, "0.005", formatPromValue(0.005))
+}
+
+func TestManager_CollectMetrics(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.ManagerDisplayName = "mgr"
+	mgr := &Manager{opts: opts, jobMetrics: newJobMetricsCollector()}
+	p := &Producer{opts: opts}
+
+	_, err = p.Enqueue("queue1", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.EnqueueIn("queue1", "Add", 60, []int{1})
+	assert.NoError(t, err)
+
+	mgr.jobMetrics.observe("queue1", "Add", false, 20*time.Millisecond, time.Second)
+	assert.NoError(t, mgr.jobMetrics.flush(context.Background(), opts.store))
+	mgr.fetchError("queue1", errors.New("connection refused"))
+
+	r := newPromRegistry()
+	assert.NoError(t, mgr.collectStoreMetrics(r))
+	mgr.collectMetrics(r)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.write(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, `sidekiq_jobs_processed_total{namespace="prod"} 0`)
+	assert.Contains(t, out, `sidekiq_queue_size{namespace="prod",queue="queue1"} 1`)
+	assert.Contains(t, out, `sidekiq_scheduled_set_size{namespace="prod"} 1`)
+	assert.Contains(t, out, `sidekiq_retry_set_size{namespace="prod"} 0`)
+	assert.Contains(t, out, `sidekiq_dead_set_size{namespace="prod"} 0`)
+	assert.Contains(t, out, `sidekiq_job_duration_seconds_bucket{namespace="prod",queue="queue1",class="Add",le="0.025"} 1`)
+	assert.Contains(t, out, `sidekiq_job_duration_seconds_count{namespace="prod",queue="queue1",class="Add"} 1`)
+	assert.Contains(t, out, `sidekiq_job_queue_time_seconds_sum{namespace="prod",queue="queue1",class="Add"} 1`)
+	assert.Contains(t, out, `sidekiq_fetch_errors_total{manager="mgr",queue="queue1"} 1`)
+	assert.NotContains(t, out, `manager="mgr",queue="queue1",class="Add"`)
+}
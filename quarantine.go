@@ -0,0 +1,87 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// QuarantinedMessage is a fetched or due payload that couldn't be parsed into a job.
+// It is kept in the quarantine set instead of being fetched over and over. Queue
+// is the queue it was fetched from, or the sorted set it was due in.
+type QuarantinedMessage struct {
+	ID            string  `json:"id"`
+	Queue         string  `json:"queue"`
+	Error         string  `json:"error"`
+	Payload       string  `json:"payload"`
+	QuarantinedAt float64 `json:"quarantined_at"`
+}
+
+// QuarantinedMessages contains a page of the quarantine set. When the messages
+// are filtered, TotalQuarantinedCount is the number of matching messages.
+type QuarantinedMessages struct {
+	TotalQuarantinedCount int64                `json:"total_quarantined_count"`
+	Messages              []QuarantinedMessage `json:"messages"`
+}
+
+// newQuarantineEntry returns the quarantine set entry of a payload that couldn't be parsed
+func newQuarantineEntry(queue string, payload string, parseErr error, at float64) string {
+	entry, _ := json.Marshal(QuarantinedMessage{
+		ID:            generateJid(),
+		Queue:         queue,
+		Error:         parseErr.Error(),
+		Payload:       payload,
+		QuarantinedAt: at,
+	})
+	return string(entry)
+}
+
+// GetQuarantinedMessages returns a page of the quarantined messages, optionally
+// filtered by the given match pattern
+func (m *Manager) GetQuarantinedMessages(page uint64, pageSize int64, match string) (QuarantinedMessages, error) {
+	total, entries, err := m.sortedSetEntries(storage.QuarantineKey, page, pageSize, match)
+	if err != nil {
+		return QuarantinedMessages{}, err
+	}
+
+	messages := []QuarantinedMessage{}
+	for _, entry := range entries {
+		var message QuarantinedMessage
+		if err := json.Unmarshal([]byte(entry.Message), &message); err != nil {
+			return QuarantinedMessages{}, err
+		}
+		messages = append(messages, message)
+	}
+
+	return QuarantinedMessages{
+		TotalQuarantinedCount: total,
+		Messages:              messages,
+	}, nil
+}
+
+// DeleteQuarantinedMessage removes the quarantined message with the given ID.
+// It reports whether the message was found.
+func (m *Manager) DeleteQuarantinedMessage(id string) (bool, error) {
+	ctx := context.Background()
+
+	entries, err := m.opts.store.ScanSortedSetMessages(ctx, storage.QuarantineKey, `*"id":"`+id+`"*`)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		var message QuarantinedMessage
+		if err := json.Unmarshal([]byte(entry.Message), &message); err == nil && message.ID == id {
+			return m.moveSortedSetEntry(ctx, storage.QuarantineKey, entry, nil)
+		}
+	}
+
+	return false, nil
+}
+
+// DeleteAllQuarantinedMessages removes every quarantined message matching the
+// pattern and returns the number of messages removed. An empty pattern matches all messages.
+func (m *Manager) DeleteAllQuarantinedMessages(match string) (int64, error) {
+	return m.moveSortedSetJobs(storage.QuarantineKey, match, nil)
+}
@@ -0,0 +1,85 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// quarantineTestMessages makes a fetcher quarantine the given payloads
+func quarantineTestMessages(t *testing.T, opts Options, queue string, payloads ...string) {
+	ctx := context.Background()
+
+	f := newSimpleFetcher(queue, opts)
+	for _, payload := range payloads {
+		assert.NoError(t, opts.client.LPush(ctx, opts.Namespace+"queue:"+f.inprogressQueue(), payload).Err())
+		f.sendMessage(payload, EventRecovered)
+	}
+}
+
+func TestFetcher_QuarantinesInvalidMessages(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+
+	quarantineTestMessages(t, opts, "queue1", "not json", `{"jid":`)
+
+	f := newSimpleFetcher("queue1", opts)
+	inProgress, err := opts.client.LLen(ctx, "prod:queue:"+f.inprogressQueue()).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), inProgress)
+
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Quarantined)
+
+	quarantined, err := mgr.GetQuarantinedMessages(0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), quarantined.TotalQuarantinedCount)
+	if assert.Len(t, quarantined.Messages, 2) {
+		message := quarantined.Messages[0]
+		assert.Equal(t, "not json", message.Payload)
+		assert.Equal(t, "queue1", message.Queue)
+		assert.NotEmpty(t, message.Error)
+		assert.NotEmpty(t, message.ID)
+		assert.NotZero(t, message.QuarantinedAt)
+	}
+}
+
+func TestManager_DeleteQuarantinedMessages(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+
+	quarantineTestMessages(t, opts, "queue1", "not json", "still not json", "broken")
+
+	quarantined, err := mgr.GetQuarantinedMessages(0, 10, "*still*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), quarantined.TotalQuarantinedCount)
+	id := quarantined.Messages[0].ID
+
+	deleted, err := mgr.DeleteQuarantinedMessage(id)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = mgr.DeleteQuarantinedMessage(id)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	count, err := mgr.DeleteAllQuarantinedMessages("*not json*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = mgr.DeleteAllQuarantinedMessages("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	quarantined, err = mgr.GetQuarantinedMessages(0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), quarantined.TotalQuarantinedCount)
+	assert.Empty(t, quarantined.Messages)
+	assert.NotNil(t, quarantined.Messages)
+}
@@ -0,0 +1,226 @@
+package workers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// QueueInfo contains the size and latency of a queue
+type QueueInfo struct {
+	Name    string  `json:"name"`
+	Size    int64   `json:"size"`
+	Latency float64 `json:"latency"`
+
+	// Number of jobs enqueued with a priority, which aren't included in Size
+	PrioritySize int64 `json:"priority_size"`
+}
+
+// QueueJobs contains a page of the jobs waiting in a queue, including the jobs enqueued with a priority
+type QueueJobs struct {
+	Queue string `json:"queue"`
+	Size  int64  `json:"size"`
+	Jobs  []*Msg `json:"jobs"`
+}
+
+// GetQueues returns the size and latency of every known queue
+func (m *Manager) GetQueues() ([]QueueInfo, error) {
+	ctx := context.Background()
+
+	names, err := m.opts.store.ListQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	queues := []QueueInfo{}
+	for _, name := range names {
+		info, err := m.GetQueue(name)
+		if err != nil {
+			return nil, err
+		}
+		queues = append(queues, info)
+	}
+
+	return queues, nil
+}
+
+// GetQueue returns the size and latency of a queue. The latency is the number
+// of seconds the oldest job in the queue has been waiting.
+func (m *Manager) GetQueue(queue string) (QueueInfo, error) {
+	ctx := context.Background()
+
+	size, err := m.opts.store.QueueSize(ctx, queue)
+	if err != nil {
+		return QueueInfo{}, err
+	}
+
+	prioritySize, err := m.opts.store.PriorityQueueSize(ctx, queue)
+	if err != nil {
+		return QueueInfo{}, err
+	}
+
+	info := QueueInfo{Name: queue, Size: size, PrioritySize: prioritySize}
+
+	// Jobs are pushed on the left and fetched from the right,
+	// so the oldest job is the last element of the list.
+	oldest, err := m.opts.store.ListMessagesInRange(ctx, queue, -1, -1)
+	if err != nil {
+		return QueueInfo{}, err
+	}
+
+	if len(oldest) > 0 {
+		if msg, err := NewMsg(oldest[0]); err == nil {
+			if enqueuedAt, err := msg.Get("enqueued_at").Float64(); err == nil {
+				info.Latency = nowToSecondsWithNanoPrecision() - enqueuedAt
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// GetQueueJobs returns a page of the jobs waiting in a queue, optionally
+// filtered by the given match pattern. Jobs are listed in the reverse order
+// they're fetched in, so the jobs enqueued with a priority come last, highest
+// priority last. Payloads that can't be parsed are left out of the page; they're
+// quarantined when they are fetched.
+func (m *Manager) GetQueueJobs(queue string, page uint64, pageSize int64, match string) (QueueJobs, error) {
+	ctx := context.Background()
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	size, err := m.opts.store.QueueSize(ctx, queue)
+	if err != nil {
+		return QueueJobs{}, err
+	}
+
+	var rawJobs []string
+	if match == "" {
+		prioritySize, err := m.opts.store.PriorityQueueSize(ctx, queue)
+		if err != nil {
+			return QueueJobs{}, err
+		}
+
+		// The page may span the end of the queue and the start of its priority set
+		start, stop := pageRange(page, pageSize)
+		if start < size {
+			listStop := stop
+			if listStop >= size {
+				listStop = size - 1
+			}
+			rawJobs, err = m.opts.store.ListMessagesInRange(ctx, queue, start, listStop)
+			if err != nil {
+				return QueueJobs{}, err
+			}
+		}
+		if stop >= size {
+			setStart := start - size
+			if setStart < 0 {
+				setStart = 0
+			}
+			entries, err := m.opts.store.ListSortedSetMessages(ctx, storage.PriorityKey(queue), setStart, stop-size)
+			if err != nil {
+				return QueueJobs{}, err
+			}
+			for _, entry := range entries {
+				rawJobs = append(rawJobs, entry.Message)
+			}
+		}
+		size += prioritySize
+	} else {
+		all, err := m.opts.store.ListMessages(ctx, queue)
+		if err != nil {
+			return QueueJobs{}, err
+		}
+
+		var matched []string
+		for _, raw := range all {
+			if storage.MatchGlob(match, raw) {
+				matched = append(matched, raw)
+			}
+		}
+
+		entries, err := m.opts.store.ScanSortedSetMessages(ctx, storage.PriorityKey(queue), match)
+		if err != nil {
+			return QueueJobs{}, err
+		}
+		for _, entry := range entries {
+			matched = append(matched, entry.Message)
+		}
+
+		size = int64(len(matched))
+		rawJobs = pageSlice(matched, page, pageSize)
+	}
+
+	jobs := []*Msg{}
+	for _, raw := range rawJobs {
+		if job, err := NewMsg(raw); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return QueueJobs{
+		Queue: queue,
+		Size:  size,
+		Jobs:  jobs,
+	}, nil
+}
+
+// DeleteQueueJob removes the job with the given JID from a queue. It reports
+// whether a job was found and removed.
+func (m *Manager) DeleteQueueJob(queue string, jid string) (bool, error) {
+	ctx := context.Background()
+
+	messages, err := m.opts.store.ListMessages(ctx, queue)
+	if err != nil {
+		return false, err
+	}
+
+	for _, raw := range messages {
+		msg, err := NewMsg(raw)
+		if err != nil || msg.Jid() != jid {
+			continue
+		}
+		return m.opts.store.DeleteMessage(ctx, queue, raw)
+	}
+
+	set := storage.PriorityKey(queue)
+	entry, found, err := m.findSortedSetJob(set, jid)
+	if err != nil || !found {
+		return false, err
+	}
+	return m.opts.store.RemoveSortedSetMessage(ctx, set, entry.Message)
+}
+
+// ClearQueue removes all jobs from a queue, including the jobs enqueued with a
+// priority, leaving the queue registered
+func (m *Manager) ClearQueue(queue string) error {
+	return m.opts.store.ClearQueue(context.Background(), queue)
+}
+
+// DeleteQueue removes all jobs from a queue, including the jobs enqueued with a
+// priority, and unregisters it
+func (m *Manager) DeleteQueue(queue string) error {
+	return m.opts.store.DeleteQueue(context.Background(), queue)
+}
+
+// pageRange converts a zero based page number into inclusive start and stop indexes
+func pageRange(page uint64, pageSize int64) (int64, int64) {
+	start := int64(page) * pageSize
+	return start, start + pageSize - 1
+}
+
+func pageSlice[T any](items []T, page uint64, pageSize int64) []T {
+	start, stop := pageRange(page, pageSize)
+	if start >= int64(len(items)) {
+		return nil
+	}
+	if stop >= int64(len(items)) {
+		stop = int64(len(items)) - 1
+	}
+	return items[start : stop+1]
+}
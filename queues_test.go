@@ -0,0 +1,216 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_GetQueues(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	queues, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Empty(t, queues)
+
+	_, err = p.Enqueue("queue2", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("queue1", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("queue1", "Add", []int{3, 4})
+	assert.NoError(t, err)
+
+	queues, err = mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Len(t, queues, 2)
+	assert.Equal(t, "queue1", queues[0].Name)
+	assert.Equal(t, int64(2), queues[0].Size)
+	assert.Greater(t, queues[0].Latency, 0.0)
+	assert.Equal(t, "queue2", queues[1].Name)
+	assert.Equal(t, int64(1), queues[1].Size)
+}
+
+func TestManager_GetQueueJobs(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	var jids []string
+	for i := 0; i < 5; i++ {
+		jid, err := p.Enqueue("queue1", "Add", []int{i})
+		assert.NoError(t, err)
+		jids = append(jids, jid)
+	}
+	_, err = p.Enqueue("queue1", "Subtract", []int{1})
+	assert.NoError(t, err)
+
+	jobs, err := mgr.GetQueueJobs("queue1", 0, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), jobs.Size)
+	assert.Len(t, jobs.Jobs, 2)
+	assert.Equal(t, "Subtract", jobs.Jobs[0].Class())
+
+	jobs, err = mgr.GetQueueJobs("queue1", 2, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, jobs.Jobs, 2)
+	assert.Equal(t, jids[0], jobs.Jobs[1].Jid())
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "*Subtract*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), jobs.Size)
+	assert.Len(t, jobs.Jobs, 1)
+
+	jobs, err = mgr.GetQueueJobs("queue1", 1, 10, "*Add*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), jobs.Size)
+	assert.Empty(t, jobs.Jobs)
+
+	// The match pattern is a glob, like the one of the sorted sets
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "*Subtr?ct*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), jobs.Size)
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "*"+storage.EscapeGlob("Subtr?ct")+"*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), jobs.Size)
+
+	// Payloads that can't be parsed don't fail the listing
+	assert.NoError(t, opts.store.EnqueueMessageNow(context.Background(), "queue1", "not json Subtract"))
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "*Subtract*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), jobs.Size)
+	assert.Len(t, jobs.Jobs, 1)
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), jobs.Size)
+	assert.Len(t, jobs.Jobs, 6)
+}
+
+func TestManager_DeleteQueueJob(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	jid, err := p.Enqueue("queue1", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("queue1", "Add", []int{2})
+	assert.NoError(t, err)
+
+	deleted, err := mgr.DeleteQueueJob("queue1", jid)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = mgr.DeleteQueueJob("queue1", jid)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	info, err := mgr.GetQueue("queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size)
+}
+
+func TestManager_ClearAndDeleteQueue(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	_, err = p.Enqueue("queue1", "Add", []int{1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.ClearQueue("queue1"))
+	queues, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Equal(t, []QueueInfo{{Name: "queue1"}}, queues)
+
+	assert.NoError(t, mgr.DeleteQueue("queue1"))
+	queues, err = mgr.GetQueues()
+	assert.NoError(t, err)
+	assert.Empty(t, queues)
+}
+
+func TestManager_GetQueues_Priority(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.PriorityFetch = true
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	_, err = p.Enqueue("queue1", "Add", []int{1, 2})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = p.EnqueueWithOptions("queue1", "Add", []int{i}, EnqueueOptions{At: nowToSecondsWithNanoPrecision(), Priority: 1})
+		assert.NoError(t, err)
+	}
+
+	queues, err := mgr.GetQueues()
+	assert.NoError(t, err)
+	if assert.Len(t, queues, 1) {
+		assert.Equal(t, int64(1), queues[0].Size)
+		assert.Equal(t, int64(2), queues[0].PrioritySize)
+	}
+
+	mgr.AddWorker("queue1", 1, func(m *Msg) error { return nil })
+	stats, err := mgr.GetStats()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"prod:queue1": 1}, stats.Enqueued)
+	assert.Equal(t, map[string]int64{"prod:queue1": 2}, stats.PriorityEnqueued)
+}
+
+func TestManager_QueueJobs_Priority(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	opts.PriorityFetch = true
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	for i := 0; i < 2; i++ {
+		_, err = p.Enqueue("queue1", "Add", []int{i})
+		assert.NoError(t, err)
+	}
+	low, err := p.EnqueueWithOptions("queue1", "Subtract", []int{1}, EnqueueOptions{At: nowToSecondsWithNanoPrecision(), Priority: 1})
+	assert.NoError(t, err)
+	high, err := p.EnqueueWithOptions("queue1", "Subtract", []int{2}, EnqueueOptions{At: nowToSecondsWithNanoPrecision(), Priority: 2})
+	assert.NoError(t, err)
+
+	// Jobs with a priority are listed after the queue, highest priority last
+	jobs, err := mgr.GetQueueJobs("queue1", 1, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), jobs.Size)
+	if assert.Len(t, jobs.Jobs, 1) {
+		assert.Equal(t, high, jobs.Jobs[0].Jid())
+	}
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 3, "")
+	assert.NoError(t, err)
+	if assert.Len(t, jobs.Jobs, 3) {
+		assert.Equal(t, "Add", jobs.Jobs[1].Class())
+		assert.Equal(t, low, jobs.Jobs[2].Jid())
+	}
+
+	jobs, err = mgr.GetQueueJobs("queue1", 0, 10, "*Subtract*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), jobs.Size)
+
+	deleted, err := mgr.DeleteQueueJob("queue1", high)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	info, err := mgr.GetQueue("queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), info.PrioritySize)
+
+	assert.NoError(t, mgr.ClearQueue("queue1"))
+	info, err = mgr.GetQueue("queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, QueueInfo{Name: "queue1"}, info)
+}
@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// GetRetries returns a page of the retry jobs for the manager, optionally
+// filtered by the given match pattern
+func (m *Manager) GetRetries(page uint64, pageSize int64, match string) (Retries, error) {
+	total, jobs, err := m.sortedSetJobs(storage.RetryKey, page, pageSize, match)
+	if err != nil {
+		return Retries{}, err
+	}
+
+	return Retries{
+		TotalRetryCount: total,
+		RetryJobs:       jobs,
+	}, nil
+}
+
+// RetryNow enqueues the retry job with the given JID for immediate processing.
+// It reports whether the job was found in the retry set.
+func (m *Manager) RetryNow(jid string) (bool, error) {
+	return m.moveSortedSetJob(storage.RetryKey, jid, m.enqueueNowAction)
+}
+
+// DeleteRetry removes the retry job with the given JID
+func (m *Manager) DeleteRetry(jid string) (bool, error) {
+	return m.moveSortedSetJob(storage.RetryKey, jid, nil)
+}
+
+// KillRetry moves the retry job with the given JID to the dead set
+func (m *Manager) KillRetry(jid string) (bool, error) {
+	return m.moveSortedSetJob(storage.RetryKey, jid, m.killAction)
+}
+
+// RetryAllRetries enqueues every retry job matching the pattern for immediate
+// processing and returns the number of jobs enqueued. An empty pattern matches all jobs.
+func (m *Manager) RetryAllRetries(match string) (int64, error) {
+	return m.moveSortedSetJobs(storage.RetryKey, match, m.enqueueNowAction)
+}
+
+// DeleteAllRetries removes every retry job matching the pattern
+func (m *Manager) DeleteAllRetries(match string) (int64, error) {
+	return m.moveSortedSetJobs(storage.RetryKey, match, nil)
+}
+
+// KillAllRetries moves every retry job matching the pattern to the dead set
+func (m *Manager) KillAllRetries(match string) (int64, error) {
+	return m.moveSortedSetJobs(storage.RetryKey, match, m.killAction)
+}
+
+// GetDeadJobs returns a page of the jobs in the dead set
+func (m *Manager) GetDeadJobs(page uint64, pageSize int64, match string) (DeadJobs, error) {
+	total, jobs, err := m.sortedSetJobs(storage.DeadKey, page, pageSize, match)
+	if err != nil {
+		return DeadJobs{}, err
+	}
+
+	return DeadJobs{
+		TotalDeadCount: total,
+		DeadJobs:       jobs,
+	}, nil
+}
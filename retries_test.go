@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pioneerworks/go-sidekiq/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func addRetries(t *testing.T, opts Options, classes ...string) []string {
+	ctx := context.Background()
+
+	var jids []string
+	for i, class := range classes {
+		jid := fmt.Sprintf("jid%d", i)
+		message, _ := NewMsg(fmt.Sprintf(`{"jid":"%s","class":"%s","queue":"%squeue1","retry":true}`, jid, class, opts.Namespace))
+		_, err := opts.client.ZAdd(ctx, retryQueue(opts.Namespace), &redis.Z{
+			Score:  nowToSecondsWithNanoPrecision() + float64(i),
+			Member: message.ToJson(),
+		}).Result()
+		assert.NoError(t, err)
+		jids = append(jids, jid)
+	}
+
+	return jids
+}
+
+func TestManager_GetRetries(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+
+	addRetries(t, opts, "Add", "Add", "Subtract", "Add", "Add")
+
+	retries, err := mgr.GetRetries(0, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), retries.TotalRetryCount)
+	assert.Len(t, retries.RetryJobs, 2)
+	assert.Equal(t, "jid0", retries.RetryJobs[0].Jid())
+
+	retries, err = mgr.GetRetries(2, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, retries.RetryJobs, 1)
+	assert.Equal(t, "jid4", retries.RetryJobs[0].Jid())
+
+	retries, err = mgr.GetRetries(0, 10, "*Subtract*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), retries.TotalRetryCount)
+	assert.Len(t, retries.RetryJobs, 1)
+	assert.Equal(t, "jid2", retries.RetryJobs[0].Jid())
+
+	retries, err = mgr.GetRetries(1, 3, "*Add*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), retries.TotalRetryCount)
+	assert.Len(t, retries.RetryJobs, 1)
+	assert.Equal(t, "jid4", retries.RetryJobs[0].Jid())
+}
+
+func TestManager_RetryActions(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	rc := opts.client
+
+	jids := addRetries(t, opts, "Add", "Subtract", "Multiply")
+
+	found, err := mgr.RetryNow(jids[0])
+	assert.NoError(t, err)
+	assert.True(t, found)
+	queued, _ := rc.LRange(ctx, "prod:queue:queue1", 0, -1).Result()
+	assert.Len(t, queued, 1)
+	msg, _ := NewMsg(queued[0])
+	assert.Equal(t, jids[0], msg.Jid())
+	assert.InDelta(t, nowToSecondsWithNanoPrecision(), msg.Get("enqueued_at").MustFloat64(), 1)
+
+	found, err = mgr.RetryNow(jids[0])
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	found, err = mgr.KillRetry(jids[1])
+	assert.NoError(t, err)
+	assert.True(t, found)
+	dead, err := mgr.GetDeadJobs(0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dead.TotalDeadCount)
+	assert.Equal(t, jids[1], dead.DeadJobs[0].Jid())
+
+	found, err = mgr.DeleteRetry(jids[2])
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	count, _ := rc.ZCard(ctx, retryQueue(opts.Namespace)).Result()
+	assert.Equal(t, int64(0), count)
+}
+
+func TestManager_BulkRetryActions(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	rc := opts.client
+
+	addRetries(t, opts, "Add", "Add", "Subtract", "Multiply", "Multiply", "Multiply")
+
+	count, err := mgr.RetryAllRetries("*Add*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	queued, _ := rc.LLen(ctx, "prod:queue:queue1").Result()
+	assert.Equal(t, int64(2), queued)
+
+	count, err = mgr.KillAllRetries("*Multiply*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	dead, _ := rc.ZCard(ctx, "prod:"+storage.DeadKey).Result()
+	assert.Equal(t, int64(3), dead)
+
+	count, err = mgr.DeleteAllRetries("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	retries, err := mgr.GetRetries(0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), retries.TotalRetryCount)
+	assert.Empty(t, retries.RetryJobs)
+}
@@ -2,13 +2,21 @@ package workers
 
 import (
 	"context"
-	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
 )
 
 type scheduledWorker struct {
 	opts Options
 	done chan bool
+
+	// Time of the last completed poll, in Unix nanoseconds
+	lastPoll int64
+
+	// onError is notified of errors moving due jobs to their queues, when set
+	onError func(message string, err error)
 }
 
 func (s *scheduledWorker) run() {
@@ -20,11 +28,17 @@ func (s *scheduledWorker) run() {
 		}
 
 		s.poll()
+		atomic.StoreInt64(&s.lastPoll, time.Now().UnixNano())
 
 		time.Sleep(s.opts.PollInterval)
 	}
 }
 
+// lastPolled returns when the last poll completed, or when the worker was created before the first one
+func (s *scheduledWorker) lastPolled() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastPoll))
+}
+
 func (s *scheduledWorker) quit() {
 	close(s.done)
 }
@@ -32,40 +46,81 @@ func (s *scheduledWorker) quit() {
 func (s *scheduledWorker) poll() {
 	now := nowToSecondsWithNanoPrecision()
 
-	for {
-		rawMessage, err := s.opts.store.DequeueScheduledMessage(context.Background(), now)
+	s.enqueueDue(storage.ScheduledJobsKey, now)
+	s.enqueueDue(storage.RetryKey, now)
+}
 
+// enqueueDue moves the jobs of a sorted set that are due to their queues. Jobs that
+// can't be enqueued are put back with their score until the next poll, and payloads
+// that can't be parsed are quarantined.
+func (s *scheduledWorker) enqueueDue(set string, now float64) {
+	ctx := context.Background()
+
+	for {
+		entries, err := s.opts.store.ListSortedSetMessages(ctx, set, 0, 0)
 		if err != nil {
-			break
+			s.reportError("", err)
+			return
 		}
+		if len(entries) == 0 || entries[0].Score > now {
+			return
+		}
+		entry := entries[0]
 
-		message, _ := NewMsg(rawMessage)
-		queue, _ := message.Get("queue").String()
-		queue = strings.TrimPrefix(queue, s.opts.Namespace)
-		message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+		// Only the process that manages to remove the job gets to enqueue it
+		removed, err := s.opts.store.RemoveSortedSetMessage(ctx, set, entry.Message)
+		if err != nil {
+			s.reportError("", err)
+			return
+		}
+		if !removed {
+			continue
+		}
 
-		s.opts.store.EnqueueMessageNow(context.Background(), queue, message.ToJson())
+		if _, parseErr := NewMsg(entry.Message); parseErr != nil {
+			s.reportError(entry.Message, parseErr)
+			err = s.quarantine(set, entry.Message, parseErr)
+		} else {
+			err = enqueueMessageNow(ctx, s.opts, entry.Message)
+		}
+		if err != nil {
+			s.reportError(entry.Message, err)
+			// Put the job back so it isn't lost
+			if err := s.opts.store.AddSortedSetMessage(ctx, set, entry.Score, entry.Message); err != nil {
+				s.reportError(entry.Message, err)
+			}
+			return
+		}
 	}
+}
 
-	for {
-		rawMessage, err := s.opts.store.DequeueRetriedMessage(context.Background(), now)
+// quarantine adds a payload of a sorted set that can't be parsed to the quarantine set
+func (s *scheduledWorker) quarantine(set string, message string, parseErr error) error {
+	ctx := context.Background()
 
-		if err != nil {
-			break
-		}
+	now := nowToSecondsWithNanoPrecision()
+	entry := newQuarantineEntry(set, message, parseErr, now)
+	if err := s.opts.store.AddSortedSetMessage(ctx, storage.QuarantineKey, now, entry); err != nil {
+		return err
+	}
 
-		message, _ := NewMsg(rawMessage)
-		queue, _ := message.Get("queue").String()
-		queue = strings.TrimPrefix(queue, s.opts.Namespace)
-		message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+	if err := s.opts.store.IncrementStats(ctx, "quarantined"); err != nil {
+		s.reportError(message, err)
+	}
+	return nil
+}
 
-		s.opts.store.EnqueueMessageNow(context.Background(), queue, message.ToJson())
+// reportError passes errors other than an empty set on to onError
+func (s *scheduledWorker) reportError(message string, err error) {
+	if err != storage.NoMessage && s.onError != nil {
+		s.onError(message, err)
 	}
 }
 
 func newScheduledWorker(opts Options) *scheduledWorker {
 	return &scheduledWorker{
-		opts: opts,
-		done: make(chan bool),
+		opts:     opts,
+		done:     make(chan bool),
+		lastPoll: time.Now().UnixNano(),
 	}
 }
@@ -0,0 +1,63 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// GetScheduledJobs returns a page of the jobs waiting to be enqueued at a
+// later time, optionally filtered by the given match pattern
+func (m *Manager) GetScheduledJobs(page uint64, pageSize int64, match string) (ScheduledJobs, error) {
+	total, jobs, err := m.sortedSetJobs(storage.ScheduledJobsKey, page, pageSize, match)
+	if err != nil {
+		return ScheduledJobs{}, err
+	}
+
+	return ScheduledJobs{
+		TotalScheduledCount: total,
+		ScheduledJobs:       jobs,
+	}, nil
+}
+
+// GetScheduledJob returns the scheduled job with the given JID. It reports
+// whether the job was found.
+func (m *Manager) GetScheduledJob(jid string) (*Msg, bool, error) {
+	entry, found, err := m.findSortedSetJob(storage.ScheduledJobsKey, jid)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	job, err := NewMsg(entry.Message)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return job, true, nil
+}
+
+// RescheduleJob changes the time at which the scheduled job with the given JID is enqueued
+func (m *Manager) RescheduleJob(jid string, at time.Time) (bool, error) {
+	return m.moveSortedSetJob(storage.ScheduledJobsKey, jid, func(ctx context.Context, entry storage.SortedEntry) error {
+		message, err := NewMsg(entry.Message)
+		if err != nil {
+			return err
+		}
+
+		score := timeToSecondsWithNanoPrecision(at)
+		message.Set("at", score)
+
+		return m.opts.store.AddSortedSetMessage(ctx, storage.ScheduledJobsKey, score, message.ToJson())
+	})
+}
+
+// EnqueueScheduledJobNow enqueues the scheduled job with the given JID for immediate processing
+func (m *Manager) EnqueueScheduledJobNow(jid string) (bool, error) {
+	return m.moveSortedSetJob(storage.ScheduledJobsKey, jid, m.enqueueNowAction)
+}
+
+// DeleteScheduledJob removes the scheduled job with the given JID
+func (m *Manager) DeleteScheduledJob(jid string) (bool, error) {
+	return m.moveSortedSetJob(storage.ScheduledJobsKey, jid, nil)
+}
@@ -0,0 +1,94 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_GetScheduledJobs(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+
+	jid1, err := p.EnqueueIn("queue1", "Add", 10, []int{1})
+	assert.NoError(t, err)
+	jid2, err := p.EnqueueIn("queue1", "Subtract", 20, []int{1})
+	assert.NoError(t, err)
+
+	scheduled, err := mgr.GetScheduledJobs(0, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), scheduled.TotalScheduledCount)
+	assert.Equal(t, jid1, scheduled.ScheduledJobs[0].Jid())
+	assert.Equal(t, jid2, scheduled.ScheduledJobs[1].Jid())
+
+	scheduled, err = mgr.GetScheduledJobs(0, 10, "*Subtract*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), scheduled.TotalScheduledCount)
+	assert.Equal(t, jid2, scheduled.ScheduledJobs[0].Jid())
+
+	job, found, err := mgr.GetScheduledJob(jid2)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Subtract", job.Class())
+
+	_, found, err = mgr.GetScheduledJob("unknown")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestManager_ScheduledJobActions(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	mgr := &Manager{opts: opts}
+	p := &Producer{opts: opts}
+	rc := opts.client
+	scheduleQueue := "prod:" + storage.ScheduledJobsKey
+
+	jid1, err := p.EnqueueIn("queue1", "Add", 10, []int{1})
+	assert.NoError(t, err)
+	jid2, err := p.EnqueueIn("queue1", "Add", 20, []int{2})
+	assert.NoError(t, err)
+	jid3, err := p.EnqueueIn("queue1", "Add", 30, []int{3})
+	assert.NoError(t, err)
+
+	at := time.Now().Add(time.Hour)
+	found, err := mgr.RescheduleJob(jid1, at)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	score, err := rc.ZScore(ctx, scheduleQueue, mustScheduledJob(t, mgr, jid1).OriginalJson()).Result()
+	assert.NoError(t, err)
+	assert.InDelta(t, timeToSecondsWithNanoPrecision(at), score, 0.001)
+	assert.InDelta(t, timeToSecondsWithNanoPrecision(at), mustScheduledJob(t, mgr, jid1).Get("at").MustFloat64(), 0.001)
+
+	found, err = mgr.EnqueueScheduledJobNow(jid2)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	queued, _ := rc.LRange(ctx, "prod:queue:queue1", 0, -1).Result()
+	assert.Len(t, queued, 1)
+	msg, _ := NewMsg(queued[0])
+	assert.Equal(t, jid2, msg.Jid())
+
+	found, err = mgr.DeleteScheduledJob(jid3)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = mgr.DeleteScheduledJob(jid3)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	count, _ := rc.ZCard(ctx, scheduleQueue).Result()
+	assert.Equal(t, int64(1), count)
+}
+
+func mustScheduledJob(t *testing.T, mgr *Manager, jid string) *Msg {
+	job, found, err := mgr.GetScheduledJob(jid)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	return job
+}
@@ -43,3 +43,83 @@ func TestScheduled(t *testing.T) {
 func retryQueue(namespace string) string {
 	return namespace + storage.RetryKey
 }
+
+func TestScheduled_Priority(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	message, _ := NewMsg("{\"queue\":\"default\",\"priority\":3}")
+	assert.NoError(t, opts.store.EnqueueScheduledMessage(ctx, nowToSecondsWithNanoPrecision()-1, message.ToJson()))
+
+	newScheduledWorker(opts).poll()
+
+	// Jobs keep their priority when they are due
+	size, err := opts.store.PriorityQueueSize(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	size, err = opts.store.QueueSize(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestScheduled_EnqueueError(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+	rc := opts.client
+
+	// Pushing to a key that isn't a list fails
+	assert.NoError(t, rc.Set(ctx, "prod:queue:broken", "x", 0).Err())
+
+	now := nowToSecondsWithNanoPrecision()
+	message, _ := NewMsg("{\"queue\":\"broken\",\"foo\":\"bar\"}")
+	assert.NoError(t, opts.store.EnqueueScheduledMessage(ctx, now-10, message.ToJson()))
+
+	var errs []error
+	scheduled := newScheduledWorker(opts)
+	scheduled.onError = func(message string, err error) {
+		errs = append(errs, err)
+	}
+	scheduled.poll()
+
+	// The job is put back with its score
+	assert.Len(t, errs, 1)
+	entries, err := opts.store.ListSortedSetMessages(ctx, storage.ScheduledJobsKey, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: now - 10, Message: message.ToJson()}}, entries)
+}
+
+func TestScheduled_Quarantine(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	now := nowToSecondsWithNanoPrecision()
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, now-10, "not json"))
+	message, _ := NewMsg("{\"queue\":\"default\",\"foo\":\"bar\"}")
+	assert.NoError(t, opts.store.EnqueueRetriedMessage(ctx, now-5, message.ToJson()))
+
+	newScheduledWorker(opts).poll()
+
+	// Payloads that can't be parsed are quarantined, and the next jobs are enqueued
+	mgr := &Manager{opts: opts}
+	quarantined, err := mgr.GetQuarantinedMessages(0, 10, "")
+	assert.NoError(t, err)
+	if assert.Len(t, quarantined.Messages, 1) {
+		assert.Equal(t, storage.RetryKey, quarantined.Messages[0].Queue)
+		assert.Equal(t, "not json", quarantined.Messages[0].Payload)
+	}
+
+	size, err := opts.store.SortedSetSize(ctx, storage.RetryKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	size, err = opts.store.QueueSize(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+}
@@ -8,10 +8,13 @@ import (
 )
 
 func (m *Manager) handleSignals() {
-	signal.Notify(m.signal, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(m.signal, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM, syscall.SIGTSTP)
 
 	for sig := range m.signal {
 		switch sig {
+		case syscall.SIGTSTP:
+			// Stop fetching new jobs, and wait for another signal to stop
+			m.Quiet()
 		case syscall.SIGINT, syscall.SIGUSR1, syscall.SIGTERM:
 			m.Stop()
 			// Don't stop more than once.
@@ -0,0 +1,185 @@
+package workers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+)
+
+// sortedSetAction is applied to a job after it has been removed from a sorted set
+type sortedSetAction func(ctx context.Context, entry storage.SortedEntry) error
+
+// sortedSetJobs returns a page of the jobs in a sorted set, along with the
+// number of jobs the page was taken from
+func (m *Manager) sortedSetJobs(set string, page uint64, pageSize int64, match string) (int64, []*Msg, error) {
+	total, entries, err := m.sortedSetEntries(set, page, pageSize, match)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	jobs := []*Msg{}
+	for _, entry := range entries {
+		job, err := NewMsg(entry.Message)
+		if err != nil {
+			return 0, nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return total, jobs, nil
+}
+
+// sortedSetEntries returns a page of the entries in a sorted set, along with the
+// number of entries the page was taken from
+func (m *Manager) sortedSetEntries(set string, page uint64, pageSize int64, match string) (int64, []storage.SortedEntry, error) {
+	ctx := context.Background()
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var (
+		total   int64
+		entries []storage.SortedEntry
+		err     error
+	)
+
+	if match == "" {
+		total, err = m.opts.store.SortedSetSize(ctx, set)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		start, stop := pageRange(page, pageSize)
+		entries, err = m.opts.store.ListSortedSetMessages(ctx, set, start, stop)
+		if err != nil {
+			return 0, nil, err
+		}
+	} else {
+		all, err := m.opts.store.ScanSortedSetMessages(ctx, set, match)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		total = int64(len(all))
+		entries = pageSlice(all, page, pageSize)
+	}
+
+	return total, entries, nil
+}
+
+// findSortedSetJob looks up the job with the given JID in a sorted set
+func (m *Manager) findSortedSetJob(set string, jid string) (storage.SortedEntry, bool, error) {
+	entries, err := m.opts.store.ScanSortedSetMessages(context.Background(), set, `*"jid":"`+jid+`"*`)
+	if err != nil {
+		return storage.SortedEntry{}, false, err
+	}
+
+	for _, entry := range entries {
+		if msg, err := NewMsg(entry.Message); err == nil && msg.Jid() == jid {
+			return entry, true, nil
+		}
+	}
+
+	return storage.SortedEntry{}, false, nil
+}
+
+// moveSortedSetJob removes the job with the given JID from a sorted set and
+// applies the action to it. It reports whether the job was found and removed.
+func (m *Manager) moveSortedSetJob(set string, jid string, action sortedSetAction) (bool, error) {
+	entry, found, err := m.findSortedSetJob(set, jid)
+	if err != nil || !found {
+		return false, err
+	}
+
+	return m.moveSortedSetEntry(context.Background(), set, entry, action)
+}
+
+// moveSortedSetJobs removes every job matching the pattern from a sorted set
+// and applies the action to each of them. An empty pattern matches all jobs.
+func (m *Manager) moveSortedSetJobs(set string, match string, action sortedSetAction) (int64, error) {
+	ctx := context.Background()
+
+	var (
+		entries []storage.SortedEntry
+		err     error
+	)
+	if match == "" {
+		entries, err = m.opts.store.ListSortedSetMessages(ctx, set, 0, -1)
+	} else {
+		entries, err = m.opts.store.ScanSortedSetMessages(ctx, set, match)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, entry := range entries {
+		moved, err := m.moveSortedSetEntry(ctx, set, entry, action)
+		if err != nil {
+			return count, err
+		}
+		if moved {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Manager) moveSortedSetEntry(ctx context.Context, set string, entry storage.SortedEntry, action sortedSetAction) (bool, error) {
+	// Only the process that manages to remove the job gets to act on it,
+	// so concurrent requests can't enqueue it twice.
+	removed, err := m.opts.store.RemoveSortedSetMessage(ctx, set, entry.Message)
+	if err != nil || !removed {
+		return false, err
+	}
+
+	if action != nil {
+		if err := action(ctx, entry); err != nil {
+			// Put the job back so it isn't lost
+			m.opts.store.AddSortedSetMessage(ctx, set, entry.Score, entry.Message)
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// enqueueNowAction pushes a job back onto its queue for immediate processing
+func (m *Manager) enqueueNowAction(ctx context.Context, entry storage.SortedEntry) error {
+	return enqueueMessageNow(ctx, m.opts, entry.Message)
+}
+
+// killAction moves a job into the dead set
+func (m *Manager) killAction(ctx context.Context, entry storage.SortedEntry) error {
+	if err := m.opts.store.AddSortedSetMessage(ctx, storage.DeadKey, nowToSecondsWithNanoPrecision(), entry.Message); err != nil {
+		return err
+	}
+
+	if m.opts.events.active() {
+		if message, err := NewMsg(entry.Message); err == nil {
+			queue, _ := message.Get("queue").String()
+			m.opts.events.publish(EventDead, strings.TrimPrefix(queue, m.opts.Namespace), message, nil)
+		}
+	}
+	return nil
+}
+
+// enqueueMessageNow pushes a raw message onto the queue named in its payload
+func enqueueMessageNow(ctx context.Context, opts Options, rawMessage string) error {
+	message, err := NewMsg(rawMessage)
+	if err != nil {
+		return err
+	}
+
+	queue, _ := message.Get("queue").String()
+	queue = strings.TrimPrefix(queue, opts.Namespace)
+	message.Set("enqueued_at", nowToSecondsWithNanoPrecision())
+
+	if priority := message.Priority(); priority != 0 {
+		return opts.store.EnqueueMessage(ctx, queue, priority, message.ToJson())
+	}
+	return opts.store.EnqueueMessageNow(ctx, queue, message.ToJson())
+}
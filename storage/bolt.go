@@ -0,0 +1,993 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets nested in the namespace bucket of a bolt store
+var (
+	boltQueuesBucket = []byte("queues")
+	boltListsBucket  = []byte("lists")
+	boltSetsBucket   = []byte("sets")
+	boltStatsBucket  = []byte("stats")
+	boltJobsBucket   = []byte("jobs")
+
+	// Each sorted set has a bucket of score keys by member hash, and a bucket
+	// of members by score key
+	boltMembersBucket = []byte("members")
+	boltScoresBucket  = []byte("scores")
+)
+
+type boltStore struct {
+	namespace string
+	bucket    []byte
+
+	db *bolt.DB
+
+	statsHistoryTTL time.Duration
+
+	// pushed is closed and replaced whenever messages are pushed onto a
+	// list, to wake up blocked calls to DequeueMessage
+	pushedLock sync.Mutex
+	pushed     chan struct{}
+}
+
+// BoltStoreOption configures optional behavior of the bolt store
+type BoltStoreOption func(*boltStore)
+
+// WithBoltStatsHistoryTTL removes the daily stats older than the given duration.
+// Daily stats are kept forever when the TTL is zero.
+func WithBoltStatsHistoryTTL(ttl time.Duration) BoltStoreOption {
+	return func(b *boltStore) {
+		b.statsHistoryTTL = ttl
+	}
+}
+
+// Compile-time check to ensure that bolt store does in fact implement the Store interface
+var _ Store = &boltStore{}
+
+// NewBoltStore returns a store keeping its data in an embedded bbolt database,
+// for single node deployments without Redis. Stores with different namespaces
+// can share the same database.
+func NewBoltStore(db *bolt.DB, namespace string, opts ...BoltStoreOption) (Store, error) {
+	b := &boltStore{
+		namespace: namespace,
+		bucket:    []byte("namespace:" + namespace),
+		db:        db,
+		pushed:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(b.bucket)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range [][]byte{boltQueuesBucket, boltListsBucket, boltSetsBucket, boltStatsBucket, boltJobsBucket} {
+			if _, err := root.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *boltStore) root(tx *bolt.Tx) *bolt.Bucket {
+	return tx.Bucket(b.bucket)
+}
+
+// notifyPushed wakes up the calls to DequeueMessage waiting for messages
+func (b *boltStore) notifyPushed() {
+	b.pushedLock.Lock()
+	defer b.pushedLock.Unlock()
+	close(b.pushed)
+	b.pushed = make(chan struct{})
+}
+
+func (b *boltStore) pushedChan() <-chan struct{} {
+	b.pushedLock.Lock()
+	defer b.pushedLock.Unlock()
+	return b.pushed
+}
+
+// Lists are buckets keyed by position, from the left of the list to its right.
+// Like with Redis, messages are pushed on the left and popped from the right.
+// The size of a list is kept as the sequence of its bucket, since counting the
+// keys of a bucket reads all of them.
+
+func listKey(queue string) []byte {
+	return []byte("queue:" + queue)
+}
+
+func encodePosition(pos int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(pos)^(1<<63))
+	return key
+}
+
+func decodePosition(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key) ^ (1 << 63))
+}
+
+// list returns the bucket of a list, or nil when the list is empty
+func (b *boltStore) list(tx *bolt.Tx, queue string) *bolt.Bucket {
+	return b.root(tx).Bucket(boltListsBucket).Bucket(listKey(queue))
+}
+
+func (b *boltStore) pushLeft(tx *bolt.Tx, queue string, message string) error {
+	list, err := b.root(tx).Bucket(boltListsBucket).CreateBucketIfNotExists(listKey(queue))
+	if err != nil {
+		return err
+	}
+
+	var pos int64
+	if first, _ := list.Cursor().First(); first != nil {
+		pos = decodePosition(first) - 1
+	}
+
+	if err := list.Put(encodePosition(pos), []byte(message)); err != nil {
+		return err
+	}
+	return addListSize(list, 1)
+}
+
+func (b *boltStore) pushRight(tx *bolt.Tx, queue string, message string) error {
+	list, err := b.root(tx).Bucket(boltListsBucket).CreateBucketIfNotExists(listKey(queue))
+	if err != nil {
+		return err
+	}
+
+	var pos int64
+	if last, _ := list.Cursor().Last(); last != nil {
+		pos = decodePosition(last) + 1
+	}
+
+	if err := list.Put(encodePosition(pos), []byte(message)); err != nil {
+		return err
+	}
+	return addListSize(list, 1)
+}
+
+func (b *boltStore) popRight(tx *bolt.Tx, queue string) (string, error) {
+	list := b.list(tx, queue)
+	if list == nil {
+		return "", NoMessage
+	}
+
+	c := list.Cursor()
+	k, v := c.Last()
+	if k == nil {
+		return "", NoMessage
+	}
+
+	message := string(v)
+	if err := c.Delete(); err != nil {
+		return "", err
+	}
+	if err := addListSize(list, -1); err != nil {
+		return "", err
+	}
+
+	return message, nil
+}
+
+func listSize(list *bolt.Bucket) int64 {
+	if list == nil {
+		return 0
+	}
+	return int64(list.Sequence())
+}
+
+func addListSize(list *bolt.Bucket, n int64) error {
+	return list.SetSequence(uint64(int64(list.Sequence()) + n))
+}
+
+// normalizeRange converts inclusive start and stop indexes, which may be negative
+// to count from the end, into a range of [0, size). ok is false when it is empty.
+func normalizeRange(start int64, stop int64, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	return start, stop, start <= stop && start < size
+}
+
+func listRange(list *bolt.Bucket, start int64, stop int64) []string {
+	start, stop, ok := normalizeRange(start, stop, listSize(list))
+	if !ok {
+		return []string{}
+	}
+
+	messages := make([]string, 0, stop-start+1)
+	c := list.Cursor()
+	var i int64
+	for k, v := c.First(); k != nil && i <= stop; k, v = c.Next() {
+		if i >= start {
+			messages = append(messages, string(v))
+		}
+		i++
+	}
+
+	return messages
+}
+
+// listRemove removes up to count occurrences of message, starting from the left
+// when count is positive and from the right when it is negative, or all of them when it is zero
+func listRemove(list *bolt.Bucket, count int, message string) (int, error) {
+	if list == nil {
+		return 0, nil
+	}
+
+	c := list.Cursor()
+	first, next := c.First, c.Next
+	if count < 0 {
+		first, next = c.Last, c.Prev
+		count = -count
+	}
+
+	// Collect the keys first, since deleting moves the cursor
+	var keys [][]byte
+	for k, v := first(); k != nil && (count == 0 || len(keys) < count); k, v = next() {
+		if string(v) == message {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+	}
+
+	for _, k := range keys {
+		if err := list.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	if err := addListSize(list, -int64(len(keys))); err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Ping fails when the database was closed
+func (b *boltStore) Ping(ctx context.Context) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+func (b *boltStore) CreateQueue(ctx context.Context, queue string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return b.root(tx).Bucket(boltQueuesBucket).Put([]byte(queue), nil)
+	})
+}
+
+func (b *boltStore) ListQueues(ctx context.Context) ([]string, error) {
+	queues := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return b.root(tx).Bucket(boltQueuesBucket).ForEach(func(k, v []byte) error {
+			queues = append(queues, string(k))
+			return nil
+		})
+	})
+	return queues, err
+}
+
+func (b *boltStore) QueueSize(ctx context.Context, queue string) (int64, error) {
+	var size int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		size = listSize(b.list(tx, queue))
+		return nil
+	})
+	return size, err
+}
+
+func (b *boltStore) ClearQueue(ctx context.Context, queue string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return b.deleteList(tx, queue)
+	})
+}
+
+// deleteList removes the list of a queue along with its priority set
+func (b *boltStore) deleteList(tx *bolt.Tx, queue string) error {
+	err := b.root(tx).Bucket(boltListsBucket).DeleteBucket(listKey(queue))
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	err = b.root(tx).Bucket(boltSetsBucket).DeleteBucket([]byte(PriorityKey(queue)))
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+func (b *boltStore) DeleteQueue(ctx context.Context, queue string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := b.deleteList(tx, queue); err != nil {
+			return err
+		}
+		return b.root(tx).Bucket(boltQueuesBucket).Delete([]byte(queue))
+	})
+}
+
+func (b *boltStore) ListMessages(ctx context.Context, queue string) ([]string, error) {
+	return b.ListMessagesInRange(ctx, queue, 0, -1)
+}
+
+func (b *boltStore) ListMessagesInRange(ctx context.Context, queue string, start int64, stop int64) ([]string, error) {
+	var messages []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		messages = listRange(b.list(tx, queue), start, stop)
+		return nil
+	})
+	return messages, err
+}
+
+func (b *boltStore) DeleteMessage(ctx context.Context, queue string, message string) (bool, error) {
+	var removed int
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		removed, err = listRemove(b.list(tx, queue), 1, message)
+		return err
+	})
+	return removed > 0, err
+}
+
+func (b *boltStore) AcknowledgeMessage(ctx context.Context, queue string, message string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		_, err := listRemove(b.list(tx, queue), -1, message)
+		return err
+	})
+}
+
+func (b *boltStore) EnqueueMessage(ctx context.Context, queue string, priority float64, message string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return b.sortedSetAdd(tx, PriorityKey(queue), priority, message)
+	})
+}
+
+func (b *boltStore) DequeuePriorityMessage(ctx context.Context, queue string, inprogressQueue string) (string, error) {
+	var message string
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, scores := b.sortedSet(tx, PriorityKey(queue))
+		if scores == nil {
+			return NoMessage
+		}
+
+		k, v := scores.Cursor().Last()
+		if k == nil {
+			return NoMessage
+		}
+
+		message = string(v)
+		if _, err := b.sortedSetRemove(tx, PriorityKey(queue), message); err != nil {
+			return err
+		}
+		return b.pushLeft(tx, inprogressQueue, message)
+	})
+	if err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+func (b *boltStore) PriorityQueueSize(ctx context.Context, queue string) (int64, error) {
+	return b.SortedSetSize(ctx, PriorityKey(queue))
+}
+
+func (b *boltStore) EnqueueMessageNow(ctx context.Context, queue string, message string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return b.pushLeft(tx, queue, message)
+	})
+	if err == nil {
+		b.notifyPushed()
+	}
+	return err
+}
+
+// timeoutChan returns a channel receiving once the timeout expired, which never
+// receives when the timeout is zero, like with Redis blocking commands
+func timeoutChan(timeout time.Duration) (<-chan time.Time, func() bool) {
+	if timeout <= 0 {
+		return nil, func() bool { return false }
+	}
+	timer := time.NewTimer(timeout)
+	return timer.C, timer.Stop
+}
+
+func (b *boltStore) DequeueMessage(ctx context.Context, queue string, inprogressQueue string, timeout time.Duration) (string, error) {
+	expired, stop := timeoutChan(timeout)
+	defer stop()
+
+	for {
+		// Get the channel before looking for a message, so a message pushed
+		// in between isn't missed
+		pushed := b.pushedChan()
+
+		var message string
+		err := b.db.Update(func(tx *bolt.Tx) (err error) {
+			if message, err = b.popRight(tx, queue); err != nil {
+				return err
+			}
+			return b.pushLeft(tx, inprogressQueue, message)
+		})
+		if err != NoMessage {
+			return message, err
+		}
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return "", NoMessage
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (b *boltStore) DequeueMessages(ctx context.Context, queue string, inprogressQueue string, count int, timeout time.Duration) ([]string, error) {
+	expired, stop := timeoutChan(timeout)
+	defer stop()
+
+	for {
+		pushed := b.pushedChan()
+
+		var messages []string
+		err := b.db.Update(func(tx *bolt.Tx) error {
+			for len(messages) < count {
+				message, err := b.popRight(tx, queue)
+				if err == NoMessage {
+					break
+				} else if err != nil {
+					return err
+				}
+				if err := b.pushLeft(tx, inprogressQueue, message); err != nil {
+					return err
+				}
+				messages = append(messages, message)
+			}
+			return nil
+		})
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return nil, NoMessage
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (b *boltStore) RequeueMessages(ctx context.Context, inprogressQueue string, queue string, messages []string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if _, err := listRemove(b.list(tx, inprogressQueue), -1, messages[i]); err != nil {
+				return err
+			}
+			if err := b.pushRight(tx, queue, messages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		b.notifyPushed()
+	}
+	return err
+}
+
+// Sorted sets are stored as a bucket of members keyed by score, and a bucket of
+// these score keys by the hash of their member, since members can be larger than
+// the maximum key size. A score key is the score followed by a sequence number,
+// so members with the same score are ordered by insertion rather than
+// lexicographically like with Redis. The size of a set is kept as the sequence
+// of its bucket of score keys.
+
+// encodeScore returns a key that sorts like the score, followed by the sequence number
+func encodeScore(score float64, seq uint64) []byte {
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, bits)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func decodeScore(key []byte) float64 {
+	bits := binary.BigEndian.Uint64(key[:8])
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+func memberKey(member string) []byte {
+	sum := sha256.Sum256([]byte(member))
+	return sum[:]
+}
+
+// sortedSet returns the members and scores buckets of a sorted set, or nils when the set is empty
+func (b *boltStore) sortedSet(tx *bolt.Tx, set string) (*bolt.Bucket, *bolt.Bucket) {
+	bucket := b.root(tx).Bucket(boltSetsBucket).Bucket([]byte(set))
+	if bucket == nil {
+		return nil, nil
+	}
+	return bucket.Bucket(boltMembersBucket), bucket.Bucket(boltScoresBucket)
+}
+
+func (b *boltStore) sortedSetSize(tx *bolt.Tx, set string) int64 {
+	members, _ := b.sortedSet(tx, set)
+	if members == nil {
+		return 0
+	}
+	return int64(members.Sequence())
+}
+
+func (b *boltStore) sortedSetAdd(tx *bolt.Tx, set string, score float64, message string) error {
+	bucket, err := b.root(tx).Bucket(boltSetsBucket).CreateBucketIfNotExists([]byte(set))
+	if err != nil {
+		return err
+	}
+	members, err := bucket.CreateBucketIfNotExists(boltMembersBucket)
+	if err != nil {
+		return err
+	}
+	scores, err := bucket.CreateBucketIfNotExists(boltScoresBucket)
+	if err != nil {
+		return err
+	}
+
+	member := memberKey(message)
+	if previous := members.Get(member); previous != nil {
+		if err := scores.Delete(previous); err != nil {
+			return err
+		}
+	} else if err := members.SetSequence(members.Sequence() + 1); err != nil {
+		return err
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	key := encodeScore(score, seq)
+	if err := members.Put(member, key); err != nil {
+		return err
+	}
+	return scores.Put(key, []byte(message))
+}
+
+func (b *boltStore) sortedSetRemove(tx *bolt.Tx, set string, message string) (bool, error) {
+	members, scores := b.sortedSet(tx, set)
+	if members == nil {
+		return false, nil
+	}
+
+	member := memberKey(message)
+	key := members.Get(member)
+	if key == nil {
+		return false, nil
+	}
+
+	if err := scores.Delete(key); err != nil {
+		return false, err
+	}
+	if err := members.Delete(member); err != nil {
+		return false, err
+	}
+	return true, members.SetSequence(members.Sequence() - 1)
+}
+
+// sortedSetPopMin removes and returns the member with the lowest score, when it isn't above max
+func (b *boltStore) sortedSetPopMin(set string, max float64) (string, error) {
+	var message string
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, scores := b.sortedSet(tx, set)
+		if scores == nil {
+			return NoMessage
+		}
+
+		k, v := scores.Cursor().First()
+		if k == nil || decodeScore(k) > max {
+			return NoMessage
+		}
+
+		message = string(v)
+		_, err := b.sortedSetRemove(tx, set, message)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+func (b *boltStore) EnqueueScheduledMessage(ctx context.Context, priority float64, message string) error {
+	return b.AddSortedSetMessage(ctx, ScheduledJobsKey, priority, message)
+}
+
+func (b *boltStore) DequeueScheduledMessage(ctx context.Context, priority float64) (string, error) {
+	return b.sortedSetPopMin(ScheduledJobsKey, priority)
+}
+
+func (b *boltStore) EnqueueRetriedMessage(ctx context.Context, priority float64, message string) error {
+	return b.AddSortedSetMessage(ctx, RetryKey, priority, message)
+}
+
+func (b *boltStore) DequeueRetriedMessage(ctx context.Context, priority float64) (string, error) {
+	return b.sortedSetPopMin(RetryKey, priority)
+}
+
+func (b *boltStore) SortedSetSize(ctx context.Context, set string) (int64, error) {
+	var size int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		size = b.sortedSetSize(tx, set)
+		return nil
+	})
+	return size, err
+}
+
+func (b *boltStore) ListSortedSetMessages(ctx context.Context, set string, start int64, stop int64) ([]SortedEntry, error) {
+	entries := []SortedEntry{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		members, scores := b.sortedSet(tx, set)
+		if members == nil {
+			return nil
+		}
+
+		start, stop, ok := normalizeRange(start, stop, int64(members.Sequence()))
+		if !ok {
+			return nil
+		}
+
+		c := scores.Cursor()
+		var i int64
+		for k, v := c.First(); k != nil && i <= stop; k, v = c.Next() {
+			if i >= start {
+				entries = append(entries, SortedEntry{Score: decodeScore(k), Message: string(v)})
+			}
+			i++
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (b *boltStore) ScanSortedSetMessages(ctx context.Context, set string, match string) ([]SortedEntry, error) {
+	var entries []SortedEntry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		_, scores := b.sortedSet(tx, set)
+		if scores == nil {
+			return nil
+		}
+
+		return scores.ForEach(func(k, v []byte) error {
+			if message := string(v); match == "" || MatchGlob(match, message) {
+				entries = append(entries, SortedEntry{Score: decodeScore(k), Message: message})
+			}
+			return nil
+		})
+	})
+	return entries, err
+}
+
+func (b *boltStore) AddSortedSetMessage(ctx context.Context, set string, score float64, message string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return b.sortedSetAdd(tx, set, score, message)
+	})
+}
+
+func (b *boltStore) RemoveSortedSetMessage(ctx context.Context, set string, message string) (bool, error) {
+	var removed bool
+	err := b.db.Update(func(tx *bolt.Tx) (err error) {
+		removed, err = b.sortedSetRemove(tx, set, message)
+		return err
+	})
+	return removed, err
+}
+
+func (b *boltStore) QuarantineMessage(ctx context.Context, queue string, message string, score float64, entry string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if _, err := listRemove(b.list(tx, queue), -1, message); err != nil {
+			return err
+		}
+		return b.sortedSetAdd(tx, QuarantineKey, score, entry)
+	})
+}
+
+func leasesKey(queue string) string {
+	return "queue:" + queue + ":leases"
+}
+
+func (b *boltStore) LeaseMessage(ctx context.Context, queue string, expiresAt float64, timeout time.Duration) (string, error) {
+	expired, stop := timeoutChan(timeout)
+	defer stop()
+
+	for {
+		pushed := b.pushedChan()
+
+		var message string
+		err := b.db.Update(func(tx *bolt.Tx) (err error) {
+			if message, err = b.popRight(tx, queue); err != nil {
+				return err
+			}
+			return b.sortedSetAdd(tx, leasesKey(queue), expiresAt, message)
+		})
+		if err != NoMessage {
+			return message, err
+		}
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return "", NoMessage
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (b *boltStore) ExtendLease(ctx context.Context, queue string, message string, expiresAt float64) (bool, error) {
+	var extended bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if members, _ := b.sortedSet(tx, leasesKey(queue)); members == nil || members.Get(memberKey(message)) == nil {
+			return nil
+		}
+
+		extended = true
+		return b.sortedSetAdd(tx, leasesKey(queue), expiresAt, message)
+	})
+	return extended, err
+}
+
+func (b *boltStore) ReleaseLease(ctx context.Context, queue string, message string) (bool, error) {
+	return b.RemoveSortedSetMessage(ctx, leasesKey(queue), message)
+}
+
+func (b *boltStore) QuarantineLeasedMessage(ctx context.Context, queue string, message string, score float64, entry string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if _, err := b.sortedSetRemove(tx, leasesKey(queue), message); err != nil {
+			return err
+		}
+		return b.sortedSetAdd(tx, QuarantineKey, score, entry)
+	})
+}
+
+func (b *boltStore) ListLeasedMessages(ctx context.Context, queue string) ([]SortedEntry, error) {
+	return b.ListSortedSetMessages(ctx, leasesKey(queue), 0, -1)
+}
+
+func (b *boltStore) ReclaimExpiredLeases(ctx context.Context, queue string, now float64) (int64, error) {
+	var reclaimed int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, scores := b.sortedSet(tx, leasesKey(queue))
+		if scores == nil {
+			return nil
+		}
+
+		var expired []string
+		c := scores.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if decodeScore(k) > now {
+				break
+			}
+			expired = append(expired, string(v))
+		}
+
+		for _, message := range expired {
+			if _, err := b.sortedSetRemove(tx, leasesKey(queue), message); err != nil {
+				return err
+			}
+			if err := b.pushRight(tx, queue, message); err != nil {
+				return err
+			}
+		}
+
+		reclaimed = int64(len(expired))
+		return nil
+	})
+	if err == nil && reclaimed > 0 {
+		b.notifyPushed()
+	}
+	return reclaimed, err
+}
+
+func (b *boltStore) GetAllRetries(ctx context.Context) (*Retries, error) {
+	entries, err := b.ListSortedSetMessages(ctx, RetryKey, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := &Retries{
+		TotalRetryCount: int64(len(entries)),
+		RetryJobs:       make([]string, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		retries.RetryJobs = append(retries.RetryJobs, entry.Message)
+	}
+
+	return retries, nil
+}
+
+// Stats are stored as decimal counters named like the Redis keys without the
+// "stat:" prefix, e.g. processed and processed:2006-01-02
+
+func getCounter(bucket *bolt.Bucket, key string) int64 {
+	count, _ := strconv.ParseInt(string(bucket.Get([]byte(key))), 10, 64)
+	return count
+}
+
+func incrementCounter(bucket *bolt.Bucket, key string, n int64) error {
+	return bucket.Put([]byte(key), []byte(strconv.FormatInt(getCounter(bucket, key)+n, 10)))
+}
+
+func (b *boltStore) IncrementStats(ctx context.Context, metric string) error {
+	now := time.Now().UTC()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		stats := b.root(tx).Bucket(boltStatsBucket)
+
+		if err := incrementCounter(stats, metric, 1); err != nil {
+			return err
+		}
+		if err := incrementCounter(stats, metric+":"+now.Format(StatsDateFormat), 1); err != nil {
+			return err
+		}
+
+		if b.statsHistoryTTL > 0 {
+			return expireDailyStats(stats, metric, now.Add(-b.statsHistoryTTL))
+		}
+		return nil
+	})
+}
+
+// expireDailyStats removes the daily counters of a metric for the days before the given time
+func expireDailyStats(stats *bolt.Bucket, metric string, before time.Time) error {
+	prefix := []byte(metric + ":")
+	oldest := before.Format(StatsDateFormat)
+
+	var expired [][]byte
+	c := stats.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, _ = c.Next() {
+		// Dates are formatted so that they sort chronologically
+		if string(k[len(prefix):]) < oldest {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+	}
+
+	for _, k := range expired {
+		if err := stats.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *boltStore) GetAllStats(ctx context.Context, queues []string) (*Stats, error) {
+	stats := &Stats{
+		Enqueued:         make(map[string]int64),
+		PriorityEnqueued: make(map[string]int64),
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		counters := b.root(tx).Bucket(boltStatsBucket)
+		stats.Processed = getCounter(counters, "processed")
+		stats.Failed = getCounter(counters, "failed")
+		stats.Quarantined = getCounter(counters, "quarantined")
+
+		stats.RetryCount = b.sortedSetSize(tx, RetryKey)
+
+		for _, queue := range queues {
+			stats.Enqueued[b.namespace+queue] = listSize(b.list(tx, queue))
+			stats.PriorityEnqueued[b.namespace+queue] = b.sortedSetSize(tx, PriorityKey(queue))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (b *boltStore) GetStatsHistory(ctx context.Context, days int, end time.Time) ([]DailyStats, error) {
+	if days <= 0 {
+		return nil, nil
+	}
+
+	history := make([]DailyStats, days)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		counters := b.root(tx).Bucket(boltStatsBucket)
+
+		end = end.UTC()
+		for i := range history {
+			date := end.AddDate(0, 0, -i)
+			history[i].Date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+			history[i].Processed = getCounter(counters, "processed:"+date.Format(StatsDateFormat))
+			history[i].Failed = getCounter(counters, "failed:"+date.Format(StatsDateFormat))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (b *boltStore) IncrementJobMetrics(ctx context.Context, metrics []JobMetrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		jobs := b.root(tx).Bucket(boltJobsBucket)
+
+		var err error
+		incrementJobMetricsFields(metrics,
+			func(field string, n int64) {
+				if err == nil {
+					err = incrementCounter(jobs, field, n)
+				}
+			},
+			func(field string, f float64) {
+				if err == nil {
+					sum, _ := strconv.ParseFloat(string(jobs.Get([]byte(field))), 64)
+					err = jobs.Put([]byte(field), []byte(strconv.FormatFloat(sum+f, 'f', -1, 64)))
+				}
+			})
+		return err
+	})
+}
+
+func (b *boltStore) GetJobMetrics(ctx context.Context) ([]JobMetrics, error) {
+	fields := map[string]string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return b.root(tx).Bucket(boltJobsBucket).ForEach(func(k, v []byte) error {
+			fields[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJobMetricsFields(fields), nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestBoltDB(t *testing.T, path string) *bolt.DB {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBoltStore_Durability(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sidekiq.db")
+
+	db, err := bolt.Open(path, 0600, nil)
+	assert.NoError(t, err)
+	store, err := NewBoltStore(db, "prod:")
+	assert.NoError(t, err)
+	assert.NoError(t, store.CreateQueue(ctx, "queue1"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "b"))
+	assert.NoError(t, store.AddSortedSetMessage(ctx, RetryKey, 1, "c"))
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, db.Close())
+	assert.Error(t, store.Ping(ctx))
+
+	store, err = NewBoltStore(openTestBoltDB(t, path), "prod:")
+	assert.NoError(t, err)
+
+	queues, err := store.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"queue1"}, queues)
+
+	messages, err := store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, messages)
+
+	retries, err := store.GetAllRetries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, retries.RetryJobs)
+
+	stats, err := store.GetAllStats(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Processed)
+}
+
+func TestBoltStore_StatsHistoryTTL(t *testing.T) {
+	ctx := context.Background()
+	db := openTestBoltDB(t, filepath.Join(t.TempDir(), "sidekiq.db"))
+
+	// Add an old daily counter directly, as if it was incremented days ago
+	store, err := NewBoltStore(db, "prod:", WithBoltStatsHistoryTTL(24*time.Hour))
+	assert.NoError(t, err)
+	old := time.Now().UTC().AddDate(0, 0, -3)
+	assert.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		stats := tx.Bucket([]byte("namespace:prod:")).Bucket(boltStatsBucket)
+		return stats.Put([]byte("processed:"+old.Format(StatsDateFormat)), []byte("5"))
+	}))
+
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+
+	history, err := store.GetStatsHistory(ctx, 4, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), history[0].Processed)
+	assert.Equal(t, int64(0), history[3].Processed)
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Job metrics are stored as counters named queue|class|metric, where metric is
+// processed, failed, or one of the _count, _sum and _bucket:<index> fields of
+// the exec and wait histograms.
+
+// incrementJobMetricsFields calls incr and incrFloat with the amount to add to each field
+func incrementJobMetricsFields(metrics []JobMetrics, incr func(field string, n int64), incrFloat func(field string, f float64)) {
+	for _, m := range metrics {
+		prefix := m.Queue + "|" + m.Class + "|"
+
+		if m.Processed != 0 {
+			incr(prefix+"processed", m.Processed)
+		}
+		if m.Failed != 0 {
+			incr(prefix+"failed", m.Failed)
+		}
+
+		incrementHistogramFields(prefix+"exec", m.ExecutionTime, incr, incrFloat)
+		incrementHistogramFields(prefix+"wait", m.QueueTime, incr, incrFloat)
+	}
+}
+
+func incrementHistogramFields(prefix string, h Histogram, incr func(field string, n int64), incrFloat func(field string, f float64)) {
+	if h.Count == 0 {
+		return
+	}
+
+	incr(prefix+"_count", h.Count)
+	incrFloat(prefix+"_sum", h.Sum)
+	for i, count := range h.Buckets {
+		if count != 0 {
+			incr(prefix+"_bucket:"+strconv.Itoa(i), count)
+		}
+	}
+}
+
+// parseJobMetricsFields converts stored fields into metrics sorted by queue and class
+func parseJobMetricsFields(fields map[string]string) []JobMetrics {
+	byJob := map[string]*JobMetrics{}
+	var keys []string
+
+	for field, value := range fields {
+		// Fields are formatted as queue|class|metric
+		parts := strings.SplitN(field, "|", 2)
+		sep := strings.LastIndex(field, "|")
+		if len(parts) != 2 || sep <= len(parts[0]) {
+			continue
+		}
+		queue, class, metric := parts[0], field[len(parts[0])+1:sep], field[sep+1:]
+
+		jobKey := queue + "|" + class
+		m, ok := byJob[jobKey]
+		if !ok {
+			m = &JobMetrics{Queue: queue, Class: class}
+			byJob[jobKey] = m
+			keys = append(keys, jobKey)
+		}
+
+		switch {
+		case metric == "processed":
+			m.Processed, _ = strconv.ParseInt(value, 10, 64)
+		case metric == "failed":
+			m.Failed, _ = strconv.ParseInt(value, 10, 64)
+		case strings.HasPrefix(metric, "exec_"):
+			parseHistogramField(&m.ExecutionTime, strings.TrimPrefix(metric, "exec_"), value)
+		case strings.HasPrefix(metric, "wait_"):
+			parseHistogramField(&m.QueueTime, strings.TrimPrefix(metric, "wait_"), value)
+		}
+	}
+
+	sort.Strings(keys)
+	metrics := make([]JobMetrics, 0, len(keys))
+	for _, key := range keys {
+		metrics = append(metrics, *byJob[key])
+	}
+
+	return metrics
+}
+
+func parseHistogramField(h *Histogram, field string, value string) {
+	switch {
+	case field == "count":
+		h.Count, _ = strconv.ParseInt(value, 10, 64)
+	case field == "sum":
+		h.Sum, _ = strconv.ParseFloat(value, 64)
+	case strings.HasPrefix(field, "bucket:"):
+		i, err := strconv.Atoi(strings.TrimPrefix(field, "bucket:"))
+		if err != nil || i < 0 {
+			return
+		}
+		for len(h.Buckets) <= i {
+			h.Buckets = append(h.Buckets, 0)
+		}
+		h.Buckets[i], _ = strconv.ParseInt(value, 10, 64)
+	}
+}
@@ -3,30 +3,61 @@ package storage
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// StatsDateFormat is the format of the date suffix of daily stats keys
+const StatsDateFormat = "2006-01-02"
+
+// Keys of the attributes added to log records, the same as the workers package's LogKeyQueue and LogKeyError
+const (
+	logKeyQueue = "queue"
+	logKeyError = "error"
+)
+
 type redisStore struct {
 	namespace string
 
-	client *redis.Client
-	logger *log.Logger
+	client redis.UniversalClient
+	logger *slog.Logger
+
+	statsHistoryTTL time.Duration
+}
+
+// RedisStoreOption configures optional behavior of the Redis store
+type RedisStoreOption func(*redisStore)
+
+// WithStatsHistoryTTL expires the daily stats keys after the given duration.
+// Daily stats are kept forever when the TTL is zero.
+func WithStatsHistoryTTL(ttl time.Duration) RedisStoreOption {
+	return func(r *redisStore) {
+		r.statsHistoryTTL = ttl
+	}
 }
 
 // Compile-time check to ensure that Redis store does in fact implement the Store interface
 var _ Store = &redisStore{}
 
-// NewRedisStore returns a new Redis store with the given namespace and preconfigured client
-func NewRedisStore(namespace string, client *redis.Client, logger *log.Logger) Store {
-	return &redisStore{
+// NewRedisStore returns a new Redis store with the given namespace and preconfigured client.
+// With Redis Cluster, the namespace must contain a hash tag so that all keys are stored in the
+// same slot, as messages are moved between keys with multi-key commands and transactions.
+func NewRedisStore(namespace string, client redis.UniversalClient, logger *slog.Logger, opts ...RedisStoreOption) Store {
+	r := &redisStore{
 		namespace: namespace,
 		client:    client,
 		logger:    logger,
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
 func (r *redisStore) DequeueMessage(ctx context.Context, queue string, inprogressQueue string, timeout time.Duration) (string, error) {
@@ -35,22 +66,70 @@ func (r *redisStore) DequeueMessage(ctx context.Context, queue string, inprogres
 	if err != nil {
 		// If redis returns null, the queue is empty.
 		// Just ignore empty queue errors; print all other errors.
+		// BRPOPLPUSH already waited for the timeout, so only wait after errors.
 		if err != redis.Nil {
-			r.logger.Println("ERR: ", queue, err)
+			r.logger.Error("couldn't dequeue message", logKeyQueue, queue, logKeyError, err)
+			time.Sleep(1 * time.Second)
 		} else {
 			err = NoMessage
 		}
 
-		time.Sleep(1 * time.Second)
-
 		return "", err
 	}
 
 	return message, nil
 }
 
+// dequeueMessagesScript moves up to ARGV[1] of the oldest messages of a queue to an in progress list
+var dequeueMessagesScript = redis.NewScript(`
+local messages = {}
+for i = 1, tonumber(ARGV[1]) do
+	local message = redis.call('RPOPLPUSH', KEYS[1], KEYS[2])
+	if not message then
+		break
+	end
+	messages[i] = message
+end
+return messages
+`)
+
+func (r *redisStore) DequeueMessages(ctx context.Context, queue string, inprogressQueue string, count int, timeout time.Duration) ([]string, error) {
+	keys := []string{r.getQueueName(queue), r.getQueueName(inprogressQueue)}
+	messages, err := dequeueMessagesScript.Run(ctx, r.client, keys, count).StringSlice()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if len(messages) > 0 {
+		return messages, nil
+	}
+
+	// Scripts can't block, so wait for the next message with BRPOPLPUSH
+	message, err := r.DequeueMessage(ctx, queue, inprogressQueue, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{message}, nil
+}
+
+func (r *redisStore) RequeueMessages(ctx context.Context, inprogressQueue string, queue string, messages []string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// Push the oldest message last, so that it is fetched first again
+		for i := len(messages) - 1; i >= 0; i-- {
+			pipe.LRem(ctx, r.getQueueName(inprogressQueue), -1, messages[i])
+			pipe.RPush(ctx, r.getQueueName(queue), messages[i])
+		}
+		return nil
+	})
+	return err
+}
+
 func (r *redisStore) EnqueueMessage(ctx context.Context, queue string, priority float64, message string) error {
-	_, err := r.client.ZAdd(ctx, r.getQueueName(queue), &redis.Z{
+	_, err := r.client.ZAdd(ctx, r.getPriorityName(queue), &redis.Z{
 		Score:  priority,
 		Member: message,
 	}).Result()
@@ -58,6 +137,30 @@ func (r *redisStore) EnqueueMessage(ctx context.Context, queue string, priority
 	return err
 }
 
+// dequeuePriorityMessageScript moves the member with the highest score of a priority set to an in progress list
+var dequeuePriorityMessageScript = redis.NewScript(`
+local messages = redis.call('ZREVRANGE', KEYS[1], 0, 0)
+if #messages == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], messages[1])
+redis.call('LPUSH', KEYS[2], messages[1])
+return messages[1]
+`)
+
+func (r *redisStore) DequeuePriorityMessage(ctx context.Context, queue string, inprogressQueue string) (string, error) {
+	keys := []string{r.getPriorityName(queue), r.getQueueName(inprogressQueue)}
+	message, err := dequeuePriorityMessageScript.Run(ctx, r.client, keys).Text()
+	if err == redis.Nil {
+		return "", NoMessage
+	}
+	return message, err
+}
+
+func (r *redisStore) PriorityQueueSize(ctx context.Context, queue string) (int64, error) {
+	return r.client.ZCard(ctx, r.getPriorityName(queue)).Result()
+}
+
 func (r *redisStore) EnqueueScheduledMessage(ctx context.Context, priority float64, message string) error {
 	_, err := r.client.ZAdd(ctx, r.namespace+ScheduledJobsKey, &redis.Z{
 		Score:  priority,
@@ -159,16 +262,231 @@ func (r *redisStore) GetAllRetries(ctx context.Context) (*Retries, error) {
 	}, nil
 }
 
+func (r *redisStore) SortedSetSize(ctx context.Context, set string) (int64, error) {
+	return r.client.ZCard(ctx, r.namespace+set).Result()
+}
+
+func (r *redisStore) ListSortedSetMessages(ctx context.Context, set string, start int64, stop int64) ([]SortedEntry, error) {
+	members, err := r.client.ZRangeWithScores(ctx, r.namespace+set, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]SortedEntry, 0, len(members))
+	for _, member := range members {
+		entries = append(entries, SortedEntry{
+			Score:   member.Score,
+			Message: member.Member.(string),
+		})
+	}
+
+	return entries, nil
+}
+
+func (r *redisStore) ScanSortedSetMessages(ctx context.Context, set string, match string) ([]SortedEntry, error) {
+	var (
+		entries []SortedEntry
+		cursor  uint64
+	)
+
+	// ZSCAN may return an element more than once
+	seen := map[string]bool{}
+
+	for {
+		values, next, err := r.client.ZScan(ctx, r.namespace+set, cursor, match, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i+1 < len(values); i += 2 {
+			if seen[values[i]] {
+				continue
+			}
+			seen[values[i]] = true
+
+			score, err := strconv.ParseFloat(values[i+1], 64)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, SortedEntry{Score: score, Message: values[i]})
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Score < entries[j].Score
+	})
+
+	return entries, nil
+}
+
+func (r *redisStore) AddSortedSetMessage(ctx context.Context, set string, score float64, message string) error {
+	_, err := r.client.ZAdd(ctx, r.namespace+set, &redis.Z{
+		Score:  score,
+		Member: message,
+	}).Result()
+
+	return err
+}
+
+func (r *redisStore) RemoveSortedSetMessage(ctx context.Context, set string, message string) (bool, error) {
+	removed, err := r.client.ZRem(ctx, r.namespace+set, message).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
+func (r *redisStore) QuarantineMessage(ctx context.Context, queue string, message string, score float64, entry string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, r.getQueueName(queue), -1, message)
+		pipe.ZAdd(ctx, r.namespace+QuarantineKey, &redis.Z{Score: score, Member: entry})
+		return nil
+	})
+	return err
+}
+
+// leaseMessageScript pops the oldest message of a queue and adds it to the lease set
+var leaseMessageScript = redis.NewScript(`
+local message = redis.call('RPOP', KEYS[1])
+if message then
+	redis.call('ZADD', KEYS[2], ARGV[1], message)
+end
+return message
+`)
+
+// extendLeaseScript updates the expiry of a lease, unless it was released or reclaimed
+var extendLeaseScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[2]) then
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// reclaimLeasesScript moves up to ARGV[2] messages with an expired lease back to
+// the end of the queue that is popped first
+var reclaimLeasesScript = redis.NewScript(`
+local messages = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, message in ipairs(messages) do
+	redis.call('ZREM', KEYS[1], message)
+	redis.call('RPUSH', KEYS[2], message)
+end
+return #messages
+`)
+
+// reclaimLeasesBatchSize is the number of expired leases reclaimed by each script call
+const reclaimLeasesBatchSize = 100
+
+// Bounds of the wait between attempts to lease a message from an empty queue
+const (
+	minLeasePollInterval = 10 * time.Millisecond
+	maxLeasePollInterval = 500 * time.Millisecond
+)
+
+func (r *redisStore) LeaseMessage(ctx context.Context, queue string, expiresAt float64, timeout time.Duration) (string, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	keys := []string{r.getQueueName(queue), r.getLeasesName(queue)}
+	wait := minLeasePollInterval
+	for {
+		message, err := leaseMessageScript.Run(ctx, r.client, keys, expiresAt).Text()
+		if err == nil {
+			return message, nil
+		}
+		if err != redis.Nil {
+			return "", err
+		}
+
+		// Scripts can't block, so poll the queue until the timeout expires like BRPOPLPUSH would
+		poll := time.NewTimer(wait)
+		select {
+		case <-poll.C:
+		case <-expired:
+			poll.Stop()
+			return "", NoMessage
+		case <-ctx.Done():
+			poll.Stop()
+			return "", ctx.Err()
+		}
+
+		if wait *= 2; wait > maxLeasePollInterval {
+			wait = maxLeasePollInterval
+		}
+	}
+}
+
+func (r *redisStore) ExtendLease(ctx context.Context, queue string, message string, expiresAt float64) (bool, error) {
+	extended, err := extendLeaseScript.Run(ctx, r.client, []string{r.getLeasesName(queue)}, expiresAt, message).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return extended == 1, nil
+}
+
+func (r *redisStore) ReleaseLease(ctx context.Context, queue string, message string) (bool, error) {
+	removed, err := r.client.ZRem(ctx, r.getLeasesName(queue), message).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
+func (r *redisStore) QuarantineLeasedMessage(ctx context.Context, queue string, message string, score float64, entry string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, r.getLeasesName(queue), message)
+		pipe.ZAdd(ctx, r.namespace+QuarantineKey, &redis.Z{Score: score, Member: entry})
+		return nil
+	})
+	return err
+}
+
+func (r *redisStore) ListLeasedMessages(ctx context.Context, queue string) ([]SortedEntry, error) {
+	return r.ListSortedSetMessages(ctx, "queue:"+queue+":leases", 0, -1)
+}
+
+func (r *redisStore) ReclaimExpiredLeases(ctx context.Context, queue string, now float64) (int64, error) {
+	keys := []string{r.getLeasesName(queue), r.getQueueName(queue)}
+
+	var reclaimed int64
+	for {
+		n, err := reclaimLeasesScript.Run(ctx, r.client, keys, now, reclaimLeasesBatchSize).Int64()
+		if err != nil {
+			return reclaimed, err
+		}
+
+		reclaimed += n
+		if n < reclaimLeasesBatchSize {
+			return reclaimed, nil
+		}
+	}
+}
+
 func (r *redisStore) GetAllStats(ctx context.Context, queues []string) (*Stats, error) {
 	pipe := r.client.Pipeline()
 
 	pGet := pipe.Get(ctx, r.namespace+"stat:processed")
 	fGet := pipe.Get(ctx, r.namespace+"stat:failed")
 	rGet := pipe.ZCard(ctx, r.namespace+RetryKey)
+	qGet := pipe.Get(ctx, r.namespace+"stat:quarantined")
 	qLen := map[string]*redis.IntCmd{}
+	pLen := map[string]*redis.IntCmd{}
 
 	for _, queue := range queues {
 		qLen[r.namespace+queue] = pipe.LLen(ctx, fmt.Sprintf("%squeue:%s", r.namespace, queue))
+		pLen[r.namespace+queue] = pipe.ZCard(ctx, r.getPriorityName(queue))
 	}
 
 	_, err := pipe.Exec(ctx)
@@ -177,31 +495,120 @@ func (r *redisStore) GetAllStats(ctx context.Context, queues []string) (*Stats,
 	}
 
 	stats := &Stats{
-		Enqueued: make(map[string]int64),
+		Enqueued:         make(map[string]int64),
+		PriorityEnqueued: make(map[string]int64),
 	}
 
 	stats.Processed, _ = strconv.ParseInt(pGet.Val(), 10, 64)
 	stats.Failed, _ = strconv.ParseInt(fGet.Val(), 10, 64)
 	stats.RetryCount = rGet.Val()
+	stats.Quarantined, _ = strconv.ParseInt(qGet.Val(), 10, 64)
 
 	for q, l := range qLen {
 		stats.Enqueued[q] = l.Val()
 	}
+	for q, l := range pLen {
+		stats.PriorityEnqueued[q] = l.Val()
+	}
 
 	return stats, nil
 }
 
+func (r *redisStore) GetStatsHistory(ctx context.Context, days int, end time.Time) ([]DailyStats, error) {
+	if days <= 0 {
+		return nil, nil
+	}
+
+	history := make([]DailyStats, days)
+	processedKeys := make([]string, days)
+	failedKeys := make([]string, days)
+
+	end = end.UTC()
+	for i := 0; i < days; i++ {
+		date := end.AddDate(0, 0, -i)
+		history[i].Date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		processedKeys[i] = r.namespace + "stat:processed:" + date.Format(StatsDateFormat)
+		failedKeys[i] = r.namespace + "stat:failed:" + date.Format(StatsDateFormat)
+	}
+
+	pipe := r.client.Pipeline()
+	processedGet := pipe.MGet(ctx, processedKeys...)
+	failedGet := pipe.MGet(ctx, failedKeys...)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i := range history {
+		history[i].Processed = parseCount(processedGet.Val()[i])
+		history[i].Failed = parseCount(failedGet.Val()[i])
+	}
+
+	return history, nil
+}
+
+func (r *redisStore) IncrementJobMetrics(ctx context.Context, metrics []JobMetrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	key := r.namespace + "stat:jobs"
+	pipe := r.client.Pipeline()
+
+	incrementJobMetricsFields(metrics,
+		func(field string, n int64) { pipe.HIncrBy(ctx, key, field, n) },
+		func(field string, f float64) { pipe.HIncrByFloat(ctx, key, field, f) })
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisStore) GetJobMetrics(ctx context.Context) ([]JobMetrics, error) {
+	fields, err := r.client.HGetAll(ctx, r.namespace+"stat:jobs").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJobMetricsFields(fields), nil
+}
+
 func (r *redisStore) AcknowledgeMessage(ctx context.Context, queue string, message string) error {
 	_, err := r.client.LRem(ctx, r.getQueueName(queue), -1, message).Result()
 
 	return err
 }
 
+func (r *redisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *redisStore) CreateQueue(ctx context.Context, queue string) error {
 	_, err := r.client.SAdd(ctx, r.namespace+"queues", queue).Result()
 	return err
 }
 
+func (r *redisStore) ListQueues(ctx context.Context) ([]string, error) {
+	return r.client.SMembers(ctx, r.namespace+"queues").Result()
+}
+
+func (r *redisStore) QueueSize(ctx context.Context, queue string) (int64, error) {
+	return r.client.LLen(ctx, r.getQueueName(queue)).Result()
+}
+
+func (r *redisStore) ClearQueue(ctx context.Context, queue string) error {
+	_, err := r.client.Del(ctx, r.getQueueName(queue), r.getPriorityName(queue)).Result()
+	return err
+}
+
+func (r *redisStore) DeleteQueue(ctx context.Context, queue string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, r.getQueueName(queue), r.getPriorityName(queue))
+	pipe.SRem(ctx, r.namespace+"queues", queue)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (r *redisStore) ListMessages(ctx context.Context, queue string) ([]string, error) {
 	messages, err := r.client.LRange(ctx, r.getQueueName(queue), 0, -1).Result()
 	if err != nil {
@@ -211,14 +618,31 @@ func (r *redisStore) ListMessages(ctx context.Context, queue string) ([]string,
 	return messages, nil
 }
 
+func (r *redisStore) ListMessagesInRange(ctx context.Context, queue string, start int64, stop int64) ([]string, error) {
+	return r.client.LRange(ctx, r.getQueueName(queue), start, stop).Result()
+}
+
+func (r *redisStore) DeleteMessage(ctx context.Context, queue string, message string) (bool, error) {
+	removed, err := r.client.LRem(ctx, r.getQueueName(queue), 1, message).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
 func (r *redisStore) IncrementStats(ctx context.Context, metric string) error {
 	rc := r.client
 
-	today := time.Now().UTC().Format("2006-01-02")
+	today := time.Now().UTC().Format(StatsDateFormat)
+	dailyKey := r.namespace + "stat:" + metric + ":" + today
 
 	pipe := rc.Pipeline()
 	pipe.Incr(ctx, r.namespace+"stat:"+metric)
-	pipe.Incr(ctx, r.namespace+"stat:"+metric+":"+today)
+	pipe.Incr(ctx, dailyKey)
+	if r.statsHistoryTTL > 0 {
+		pipe.Expire(ctx, dailyKey, r.statsHistoryTTL)
+	}
 
 	if _, err := pipe.Exec(ctx); err != nil {
 		return err
@@ -227,6 +651,25 @@ func (r *redisStore) IncrementStats(ctx context.Context, metric string) error {
 	return nil
 }
 
+// parseCount converts an MGET value into a counter, treating missing keys as zero
+func parseCount(value interface{}) int64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+
+	count, _ := strconv.ParseInt(s, 10, 64)
+	return count
+}
+
 func (r *redisStore) getQueueName(queue string) string {
 	return r.namespace + "queue:" + queue
 }
+
+func (r *redisStore) getPriorityName(queue string) string {
+	return r.namespace + PriorityKey(queue)
+}
+
+func (r *redisStore) getLeasesName(queue string) string {
+	return r.namespace + "queue:" + queue + ":leases"
+}
@@ -8,8 +8,15 @@ import (
 const (
 	RetryKey         = "retry"
 	ScheduledJobsKey = "schedule"
+	DeadKey          = "dead"
+	QuarantineKey    = "quarantine"
 )
 
+// PriorityKey returns the name of the sorted set holding the messages enqueued with a priority to a queue
+func PriorityKey(queue string) string {
+	return "queue:" + queue + ":priority"
+}
+
 // StorageError is used to return errors from the storage layer
 type StorageError string
 
@@ -22,10 +29,39 @@ const (
 
 // Stats has all the stats related to a manager
 type Stats struct {
-	Processed  int64
-	Failed     int64
-	RetryCount int64
-	Enqueued   map[string]int64
+	Processed   int64
+	Failed      int64
+	RetryCount  int64
+	Quarantined int64
+	Enqueued    map[string]int64
+	// Number of messages in the priority set of each queue
+	PriorityEnqueued map[string]int64
+}
+
+// DailyStats has the processed and failed counters of a single day
+type DailyStats struct {
+	Date      time.Time
+	Processed int64
+	Failed    int64
+}
+
+// Histogram has the number and sum of observations along with the count of
+// observations falling in each bucket. The buckets are not cumulative and the
+// meaning of each bucket index is defined by the caller.
+type Histogram struct {
+	Count   int64
+	Sum     float64
+	Buckets []int64
+}
+
+// JobMetrics has the execution metrics of a class of jobs in a queue
+type JobMetrics struct {
+	Queue         string
+	Class         string
+	Processed     int64
+	Failed        int64
+	ExecutionTime Histogram
+	QueueTime     Histogram
 }
 
 // Retries has the list of messages in the retry queue
@@ -34,16 +70,44 @@ type Retries struct {
 	RetryJobs       []string
 }
 
+// SortedEntry is a message stored in a sorted set along with its score
+type SortedEntry struct {
+	Score   float64
+	Message string
+}
+
 // Store is the interface for storing and retrieving data
 type Store interface {
+	// Ping checks that the store can be reached
+	Ping(ctx context.Context) error
 
 	// General queue operations
 	CreateQueue(ctx context.Context, queue string) error
+	ListQueues(ctx context.Context) ([]string, error)
+	QueueSize(ctx context.Context, queue string) (int64, error)
+	// ClearQueue removes all messages from a queue and its priority set
+	ClearQueue(ctx context.Context, queue string) error
+	// DeleteQueue removes all messages from a queue and its priority set, and unregisters the queue
+	DeleteQueue(ctx context.Context, queue string) error
 	ListMessages(ctx context.Context, queue string) ([]string, error)
+	ListMessagesInRange(ctx context.Context, queue string, start int64, stop int64) ([]string, error)
+	DeleteMessage(ctx context.Context, queue string, message string) (bool, error)
 	AcknowledgeMessage(ctx context.Context, queue string, message string) error
+	// EnqueueMessage adds a message to the priority set of a queue, where messages with the highest priority are dequeued first
 	EnqueueMessage(ctx context.Context, queue string, priority float64, message string) error
+	// DequeuePriorityMessage moves the message with the highest priority of a queue to an in progress
+	// queue, or returns NoMessage right away when the priority set is empty
+	DequeuePriorityMessage(ctx context.Context, queue string, inprogressQueue string) (string, error)
+	PriorityQueueSize(ctx context.Context, queue string) (int64, error)
 	EnqueueMessageNow(ctx context.Context, queue string, message string) error
+	// DequeueMessage moves the oldest message of a queue to an in progress queue, waiting up to
+	// timeout for a message when the queue is empty, or until one is enqueued when timeout is zero
 	DequeueMessage(ctx context.Context, queue string, inprogressQueue string, timeout time.Duration) (string, error)
+	// DequeueMessages moves up to count of the oldest messages of a queue to an in progress queue,
+	// waiting up to timeout for a message when the queue is empty, or until one is enqueued when timeout is zero
+	DequeueMessages(ctx context.Context, queue string, inprogressQueue string, count int, timeout time.Duration) ([]string, error)
+	// RequeueMessages moves dequeued messages, oldest first, back from an in progress queue to be fetched first
+	RequeueMessages(ctx context.Context, inprogressQueue string, queue string, messages []string) error
 
 	// Special purpose queue operations
 	EnqueueScheduledMessage(ctx context.Context, priority float64, message string) error
@@ -52,9 +116,36 @@ type Store interface {
 	EnqueueRetriedMessage(ctx context.Context, priority float64, message string) error
 	DequeueRetriedMessage(ctx context.Context, priority float64) (string, error)
 
+	// Sorted set operations, where set is one of RetryKey, ScheduledJobsKey, DeadKey, QuarantineKey or the PriorityKey of a queue
+	SortedSetSize(ctx context.Context, set string) (int64, error)
+	ListSortedSetMessages(ctx context.Context, set string, start int64, stop int64) ([]SortedEntry, error)
+	ScanSortedSetMessages(ctx context.Context, set string, match string) ([]SortedEntry, error)
+	AddSortedSetMessage(ctx context.Context, set string, score float64, message string) error
+	RemoveSortedSetMessage(ctx context.Context, set string, message string) (bool, error)
+
+	// QuarantineMessage atomically removes a message from a queue and adds entry to the quarantine set
+	QuarantineMessage(ctx context.Context, queue string, message string, score float64, entry string) error
+
+	// Lease operations, where messages are moved from a queue to the lease set of the queue, scored
+	// by the expiry of their lease (in seconds since the epoch), until they are released. LeaseMessage
+	// waits up to timeout for a message when the queue is empty, or until one is enqueued when
+	// timeout is zero, so expiresAt should allow for the time spent waiting.
+	LeaseMessage(ctx context.Context, queue string, expiresAt float64, timeout time.Duration) (string, error)
+	ExtendLease(ctx context.Context, queue string, message string, expiresAt float64) (bool, error)
+	ReleaseLease(ctx context.Context, queue string, message string) (bool, error)
+	// QuarantineLeasedMessage atomically releases the lease of a message and adds entry to the quarantine set
+	QuarantineLeasedMessage(ctx context.Context, queue string, message string, score float64, entry string) error
+	ListLeasedMessages(ctx context.Context, queue string) ([]SortedEntry, error)
+	// ReclaimExpiredLeases moves the messages with a lease expired at the given time back to the
+	// queue, to be fetched first, and returns how many were moved
+	ReclaimExpiredLeases(ctx context.Context, queue string, now float64) (int64, error)
+
 	// Stats
 	IncrementStats(ctx context.Context, metric string) error
 	GetAllStats(ctx context.Context, queues []string) (*Stats, error)
+	GetStatsHistory(ctx context.Context, days int, end time.Time) ([]DailyStats, error)
+	IncrementJobMetrics(ctx context.Context, metrics []JobMetrics) error
+	GetJobMetrics(ctx context.Context) ([]JobMetrics, error)
 
 	// Retries
 	GetAllRetries(ctx context.Context) (*Retries, error)
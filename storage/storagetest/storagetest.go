@@ -0,0 +1,815 @@
+// Package storagetest checks that implementations of storage.Store behave
+// like the Redis store used by the workers.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pioneerworks/go-sidekiq/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// NewStoreFunc returns a store using the given namespace, which ends with a colon.
+// Stores returned for different namespaces must share the same data, so that
+// the suite can check that namespaces are isolated from each other.
+type NewStoreFunc func(t *testing.T, namespace string) storage.Store
+
+// tests are run against a new namespace each, so the stores don't need to be
+// emptied between tests
+var tests = []struct {
+	name string
+	test func(t *testing.T, s *suite)
+}{
+	{"Ping", testPing},
+	{"Queues", testQueues},
+	{"Messages", testMessages},
+	{"Dequeue", testDequeue},
+	{"DequeueTimeout", testDequeueTimeout},
+	{"DequeueBatch", testDequeueBatch},
+	{"PriorityQueues", testPriorityQueues},
+	{"Concurrency", testConcurrency},
+	{"NamespaceIsolation", testNamespaceIsolation},
+	{"SortedSets", testSortedSets},
+	{"Scheduled", testScheduled},
+	{"Quarantine", testQuarantine},
+	{"Leases", testLeases},
+	{"Stats", testStats},
+	{"StatsHistory", testStatsHistory},
+	{"JobMetrics", testJobMetrics},
+}
+
+// Run exercises every method of the stores returned by newStore as subtests of t.
+func Run(t *testing.T, newStore NewStoreFunc) {
+	s := &suite{
+		newStore: newStore,
+		prefix:   fmt.Sprintf("storagetest%d", time.Now().UnixNano()),
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, s)
+		})
+	}
+}
+
+type suite struct {
+	newStore NewStoreFunc
+	prefix   string
+	count    int64
+}
+
+// store returns a store using a namespace that no other test uses
+func (s *suite) store(t *testing.T) (storage.Store, string) {
+	namespace := fmt.Sprintf("%s-%d:", s.prefix, atomic.AddInt64(&s.count, 1))
+	return s.newStore(t, namespace), namespace
+}
+
+func testDequeueTimeout(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	// Stores may not support timeouts shorter than a second
+	start := time.Now()
+	_, err := store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", time.Second)
+	assert.Equal(t, storage.NoMessage, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	size, err := store.QueueSize(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	// A zero timeout waits until a message is enqueued
+	go func() {
+		time.Sleep(1100 * time.Millisecond)
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "b"))
+	}()
+
+	start = time.Now()
+	message, err := store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", message)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	messages, err := store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, messages)
+}
+
+func testDequeueBatch(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	start := time.Now()
+	_, err := store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 2, time.Second)
+	assert.Equal(t, storage.NoMessage, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	for _, message := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", message))
+	}
+
+	// Oldest messages first, moved to the in progress queue
+	messages, err := store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 2, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, messages)
+
+	messages, err = store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 2, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, messages)
+
+	inprogress, err := store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a"}, inprogress)
+
+	// Requeued messages are fetched first, in the same order
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "d"))
+	assert.NoError(t, store.RequeueMessages(ctx, "queue1:1:inprogress", "queue1", []string{"b", "c"}))
+	assert.NoError(t, store.RequeueMessages(ctx, "queue1:1:inprogress", "queue1", nil))
+
+	inprogress, err = store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, inprogress)
+
+	messages, err = store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 10, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, messages)
+
+	// Blocked dequeues get messages pushed while they wait
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		store.EnqueueMessageNow(ctx, "queue1", "e")
+	}()
+	messages, err = store.DequeueMessages(ctx, "queue1", "queue1:1:inprogress", 10, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e"}, messages)
+}
+
+func testPriorityQueues(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, namespace := s.store(t)
+
+	_, err := store.DequeuePriorityMessage(ctx, "queue1", "queue1:1:inprogress")
+	assert.Equal(t, storage.NoMessage, err)
+
+	assert.NoError(t, store.EnqueueMessage(ctx, "queue1", 1, "low"))
+	assert.NoError(t, store.EnqueueMessage(ctx, "queue1", 10, "high"))
+	assert.NoError(t, store.EnqueueMessage(ctx, "queue1", 5, "medium"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "normal"))
+
+	// Priority sets and lists of the same queue are separate
+	size, err := store.PriorityQueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), size)
+
+	size, err = store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	stats, err := store.GetAllStats(ctx, []string{"queue1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{namespace + "queue1": 1}, stats.Enqueued)
+	assert.Equal(t, map[string]int64{namespace + "queue1": 3}, stats.PriorityEnqueued)
+
+	// Highest priority first, moved to the in progress queue
+	for _, expected := range []string{"high", "medium", "low"} {
+		message, err := store.DequeuePriorityMessage(ctx, "queue1", "queue1:1:inprogress")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, message)
+	}
+
+	_, err = store.DequeuePriorityMessage(ctx, "queue1", "queue1:1:inprogress")
+	assert.Equal(t, storage.NoMessage, err)
+
+	messages, err := store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low", "medium", "high"}, messages)
+
+	assert.NoError(t, store.AcknowledgeMessage(ctx, "queue1:1:inprogress", "medium"))
+	messages, err = store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low", "high"}, messages)
+}
+
+func testConcurrency(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	const producers, consumers, messagesPerProducer = 4, 4, 25
+
+	var wg sync.WaitGroup
+	var expected []string
+	for p := 0; p < producers; p++ {
+		messages := make([]string, messagesPerProducer)
+		for i := range messages {
+			messages[i] = fmt.Sprintf("message-%d-%d", p, i)
+		}
+		expected = append(expected, messages...)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, message := range messages {
+				assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", message))
+			}
+		}()
+	}
+
+	// Consumers start while messages are still being enqueued
+	var lock sync.Mutex
+	var dequeued []string
+	for c := 0; c < consumers; c++ {
+		inprogress := fmt.Sprintf("queue1:%d:inprogress", c)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				message, err := store.DequeueMessage(ctx, "queue1", inprogress, time.Second)
+				if err == storage.NoMessage {
+					return
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				lock.Lock()
+				dequeued = append(dequeued, message)
+				lock.Unlock()
+
+				assert.NoError(t, store.AcknowledgeMessage(ctx, inprogress, message))
+				assert.NoError(t, store.IncrementStats(ctx, "processed"))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Every message is dequeued exactly once
+	sort.Strings(expected)
+	sort.Strings(dequeued)
+	assert.Equal(t, expected, dequeued)
+
+	size, err := store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	for c := 0; c < consumers; c++ {
+		size, err := store.QueueSize(ctx, fmt.Sprintf("queue1:%d:inprogress", c))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), size)
+	}
+
+	stats, err := store.GetAllStats(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(producers*messagesPerProducer), stats.Processed)
+}
+
+func testNamespaceIsolation(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+	other, _ := s.store(t)
+
+	assert.NoError(t, store.CreateQueue(ctx, "queue1"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 1, "b"))
+	assert.NoError(t, store.EnqueueScheduledMessage(ctx, 1, "c"))
+	assert.NoError(t, store.EnqueueRetriedMessage(ctx, 1, "d"))
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, store.IncrementJobMetrics(ctx, []storage.JobMetrics{{Queue: "queue1", Class: "Add", Processed: 1}}))
+
+	queues, err := other.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, queues)
+
+	size, err := other.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	_, err = other.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", time.Second)
+	assert.Equal(t, storage.NoMessage, err)
+
+	for _, set := range []string{storage.DeadKey, storage.ScheduledJobsKey, storage.RetryKey} {
+		size, err := other.SortedSetSize(ctx, set)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), size, set)
+	}
+
+	_, err = other.DequeueScheduledMessage(ctx, 2)
+	assert.Equal(t, storage.NoMessage, err)
+
+	stats, err := other.GetAllStats(ctx, []string{"queue1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Processed)
+	assert.Equal(t, int64(0), stats.RetryCount)
+
+	history, err := other.GetStatsHistory(ctx, 1, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), history[0].Processed)
+
+	metrics, err := other.GetJobMetrics(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, metrics)
+
+	// Clearing a queue in one namespace leaves the other one alone
+	assert.NoError(t, other.DeleteQueue(ctx, "queue1"))
+	size, err = store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+}
+
+func testPing(t *testing.T, s *suite) {
+	store, _ := s.store(t)
+	assert.NoError(t, store.Ping(context.Background()))
+}
+
+func testQueues(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	queues, err := store.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, queues)
+
+	assert.NoError(t, store.CreateQueue(ctx, "queue1"))
+	assert.NoError(t, store.CreateQueue(ctx, "queue2"))
+	assert.NoError(t, store.CreateQueue(ctx, "queue1"))
+
+	queues, err = store.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"queue1", "queue2"}, queues)
+
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.EnqueueMessage(ctx, "queue1", 1, "c"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue2", "b"))
+	assert.NoError(t, store.EnqueueMessage(ctx, "queue2", 1, "d"))
+
+	// The priority set of the queue is cleared too
+	assert.NoError(t, store.ClearQueue(ctx, "queue1"))
+	size, err := store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	size, err = store.PriorityQueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	assert.NoError(t, store.DeleteQueue(ctx, "queue2"))
+	size, err = store.QueueSize(ctx, "queue2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	size, err = store.PriorityQueueSize(ctx, "queue2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	queues, err = store.ListQueues(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"queue1"}, queues)
+
+	// Deleting a missing queue isn't an error
+	assert.NoError(t, store.DeleteQueue(ctx, "missing"))
+	assert.NoError(t, store.ClearQueue(ctx, "missing"))
+}
+
+func testMessages(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	messages, err := store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+
+	for _, message := range []string{"a", "b", "c", "b", "d"} {
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", message))
+	}
+
+	size, err := store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), size)
+
+	// Newest messages first
+	messages, err = store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "b", "c", "b", "a"}, messages)
+
+	messages, err = store.ListMessagesInRange(ctx, "queue1", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, messages)
+
+	messages, err = store.ListMessagesInRange(ctx, "queue1", -2, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, messages)
+
+	messages, err = store.ListMessagesInRange(ctx, "queue1", 3, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, messages)
+
+	messages, err = store.ListMessagesInRange(ctx, "queue1", 10, 20)
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// Deleting removes the newest occurrence
+	deleted, err := store.DeleteMessage(ctx, "queue1", "b")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	messages, err = store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, messages)
+
+	deleted, err = store.DeleteMessage(ctx, "queue1", "missing")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	// Acknowledging removes the oldest occurrence
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "c"))
+	assert.NoError(t, store.AcknowledgeMessage(ctx, "queue1", "c"))
+	messages, err = store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d", "b", "a"}, messages)
+}
+
+func testDequeue(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "b"))
+
+	// Oldest messages first, moved to the in progress queue
+	message, err := store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", message)
+
+	messages, err := store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, messages)
+
+	message, err = store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", message)
+
+	assert.NoError(t, store.AcknowledgeMessage(ctx, "queue1:1:inprogress", "a"))
+	messages, err = store.ListMessages(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, messages)
+
+	_, err = store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", 100*time.Millisecond)
+	assert.Equal(t, storage.NoMessage, err)
+
+	// Blocked dequeues get messages pushed while they wait
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		store.EnqueueMessageNow(ctx, "queue1", "c")
+	}()
+	message, err = store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", message)
+}
+
+func testSortedSets(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	size, err := store.SortedSetSize(ctx, storage.DeadKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 3, `{"jid":"c"}`))
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 1, `{"jid":"a"}`))
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 2, `{"jid":"b"}`))
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, -1.5, `{"jid":"d"}`))
+	// Adding an existing message updates its score
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 4, `{"jid":"a"}`))
+
+	size, err = store.SortedSetSize(ctx, storage.DeadKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), size)
+
+	entries, err := store.ListSortedSetMessages(ctx, storage.DeadKey, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{
+		{Score: -1.5, Message: `{"jid":"d"}`},
+		{Score: 2, Message: `{"jid":"b"}`},
+		{Score: 3, Message: `{"jid":"c"}`},
+		{Score: 4, Message: `{"jid":"a"}`},
+	}, entries)
+
+	entries, err = store.ListSortedSetMessages(ctx, storage.DeadKey, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{
+		{Score: 2, Message: `{"jid":"b"}`},
+		{Score: 3, Message: `{"jid":"c"}`},
+	}, entries)
+
+	entries, err = store.ListSortedSetMessages(ctx, storage.DeadKey, 10, 20)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = store.ScanSortedSetMessages(ctx, storage.DeadKey, `*"b"*`)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 2, Message: `{"jid":"b"}`}}, entries)
+
+	entries, err = store.ScanSortedSetMessages(ctx, storage.DeadKey, `*"[ab]"*`)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	removed, err := store.RemoveSortedSetMessage(ctx, storage.DeadKey, `{"jid":"b"}`)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = store.RemoveSortedSetMessage(ctx, storage.DeadKey, `{"jid":"b"}`)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	// Messages can be large
+	large := `{"args":["` + strings.Repeat("x", 64*1024) + `"]}`
+	assert.NoError(t, store.AddSortedSetMessage(ctx, storage.DeadKey, 5, large))
+	entries, err = store.ListSortedSetMessages(ctx, storage.DeadKey, -1, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 5, Message: large}}, entries)
+
+	removed, err = store.RemoveSortedSetMessage(ctx, storage.DeadKey, large)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	size, err = store.SortedSetSize(ctx, storage.DeadKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), size)
+
+	// Sets are independent
+	size, err = store.SortedSetSize(ctx, storage.RetryKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func testScheduled(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	assert.NoError(t, store.EnqueueScheduledMessage(ctx, 20, "later"))
+	assert.NoError(t, store.EnqueueScheduledMessage(ctx, 10, "sooner"))
+	assert.NoError(t, store.EnqueueRetriedMessage(ctx, 10, "retry"))
+
+	_, err := store.DequeueScheduledMessage(ctx, 5)
+	assert.Equal(t, storage.NoMessage, err)
+
+	message, err := store.DequeueScheduledMessage(ctx, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, "sooner", message)
+
+	_, err = store.DequeueScheduledMessage(ctx, 15)
+	assert.Equal(t, storage.NoMessage, err)
+
+	retries, err := store.GetAllRetries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), retries.TotalRetryCount)
+	assert.Equal(t, []string{"retry"}, retries.RetryJobs)
+
+	message, err = store.DequeueRetriedMessage(ctx, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, "retry", message)
+
+	_, err = store.DequeueRetriedMessage(ctx, 15)
+	assert.Equal(t, storage.NoMessage, err)
+
+	size, err := store.SortedSetSize(ctx, storage.ScheduledJobsKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+}
+
+func testQuarantine(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "bad"))
+	message, err := store.DequeueMessage(ctx, "queue1", "queue1:1:inprogress", time.Second)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.QuarantineMessage(ctx, "queue1:1:inprogress", message, 1, "entry"))
+
+	size, err := store.QueueSize(ctx, "queue1:1:inprogress")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	entries, err := store.ListSortedSetMessages(ctx, storage.QuarantineKey, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 1, Message: "entry"}}, entries)
+
+	// Leased messages are quarantined by releasing their lease
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue2", "bad"))
+	message, err = store.LeaseMessage(ctx, "queue2", 100, time.Second)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.QuarantineLeasedMessage(ctx, "queue2", message, 2, "leased entry"))
+
+	leases, err := store.ListLeasedMessages(ctx, "queue2")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+
+	entries, err = store.ListSortedSetMessages(ctx, storage.QuarantineKey, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 1, Message: "entry"}, {Score: 2, Message: "leased entry"}}, entries)
+}
+
+func testStats(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, namespace := s.store(t)
+
+	stats, err := store.GetAllStats(ctx, []string{"queue1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Processed)
+	assert.Equal(t, map[string]int64{namespace + "queue1": 0}, stats.Enqueued)
+
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, store.IncrementStats(ctx, "failed"))
+	assert.NoError(t, store.IncrementStats(ctx, "quarantined"))
+	assert.NoError(t, store.EnqueueRetriedMessage(ctx, 1, "retry"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "b"))
+
+	stats, err = store.GetAllStats(ctx, []string{"queue1", "queue2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Processed)
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.Equal(t, int64(1), stats.Quarantined)
+	assert.Equal(t, int64(1), stats.RetryCount)
+	assert.Equal(t, map[string]int64{namespace + "queue1": 2, namespace + "queue2": 0}, stats.Enqueued)
+}
+
+func testStatsHistory(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, store.IncrementStats(ctx, "processed"))
+	assert.NoError(t, store.IncrementStats(ctx, "failed"))
+
+	now := time.Now().UTC()
+	history, err := store.GetStatsHistory(ctx, 3, now)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), history[0].Date)
+	assert.Equal(t, int64(2), history[0].Processed)
+	assert.Equal(t, int64(1), history[0].Failed)
+	assert.Equal(t, history[0].Date.AddDate(0, 0, -1), history[1].Date)
+	assert.Equal(t, int64(0), history[1].Processed)
+
+	history, err = store.GetStatsHistory(ctx, 0, now)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func testJobMetrics(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	metrics, err := store.GetJobMetrics(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, metrics)
+
+	update := []storage.JobMetrics{
+		{
+			Queue:         "queue1",
+			Class:         "Add",
+			Processed:     2,
+			Failed:        1,
+			ExecutionTime: storage.Histogram{Count: 3, Sum: 1.5, Buckets: []int64{1, 2}},
+			QueueTime:     storage.Histogram{Count: 3, Sum: 0.25, Buckets: []int64{3}},
+		},
+		{
+			Queue:     "queue2",
+			Class:     "Sub",
+			Processed: 1,
+		},
+	}
+	assert.NoError(t, store.IncrementJobMetrics(ctx, update))
+	assert.NoError(t, store.IncrementJobMetrics(ctx, update[:1]))
+	assert.NoError(t, store.IncrementJobMetrics(ctx, nil))
+
+	metrics, err = store.GetJobMetrics(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, metrics, 2) {
+		assert.Equal(t, "queue1", metrics[0].Queue)
+		assert.Equal(t, "Add", metrics[0].Class)
+		assert.Equal(t, int64(4), metrics[0].Processed)
+		assert.Equal(t, int64(2), metrics[0].Failed)
+		assert.Equal(t, int64(6), metrics[0].ExecutionTime.Count)
+		assert.InDelta(t, 3.0, metrics[0].ExecutionTime.Sum, 1e-9)
+		assert.Equal(t, []int64{2, 4}, metrics[0].ExecutionTime.Buckets)
+		assert.InDelta(t, 0.5, metrics[0].QueueTime.Sum, 1e-9)
+		assert.Equal(t, "queue2", metrics[1].Queue)
+		assert.Equal(t, int64(1), metrics[1].Processed)
+	}
+}
+
+func testLeases(t *testing.T, s *suite) {
+	ctx := context.Background()
+	store, _ := s.store(t)
+
+	start := time.Now()
+	_, err := store.LeaseMessage(ctx, "queue1", 100, time.Second)
+	assert.Equal(t, storage.NoMessage, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "a"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "b"))
+	assert.NoError(t, store.EnqueueMessageNow(ctx, "queue1", "c"))
+
+	// Oldest messages first, moved to the lease set
+	message, err := store.LeaseMessage(ctx, "queue1", 100, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", message)
+	message, err = store.LeaseMessage(ctx, "queue1", 110, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", message)
+
+	size, err := store.QueueSize(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	leases, err := store.ListLeasedMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 100, Message: "a"}, {Score: 110, Message: "b"}}, leases)
+
+	extended, err := store.ExtendLease(ctx, "queue1", "a", 120)
+	assert.NoError(t, err)
+	assert.True(t, extended)
+
+	extended, err = store.ExtendLease(ctx, "queue1", "missing", 120)
+	assert.NoError(t, err)
+	assert.False(t, extended)
+
+	// Only expired leases are reclaimed, and fetched before the other messages
+	reclaimed, err := store.ReclaimExpiredLeases(ctx, "queue1", 115)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), reclaimed)
+
+	leases, err = store.ListLeasedMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.SortedEntry{{Score: 120, Message: "a"}}, leases)
+
+	message, err = store.LeaseMessage(ctx, "queue1", 130, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", message)
+
+	// Reclaimed leases can't be extended or released
+	reclaimed, err = store.ReclaimExpiredLeases(ctx, "queue1", 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), reclaimed)
+
+	extended, err = store.ExtendLease(ctx, "queue1", "a", 1100)
+	assert.NoError(t, err)
+	assert.False(t, extended)
+
+	released, err := store.ReleaseLease(ctx, "queue1", "a")
+	assert.NoError(t, err)
+	assert.False(t, released)
+
+	messages, err := store.ListMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "a", "b"}, messages)
+
+	message, err = store.LeaseMessage(ctx, "queue1", 100, time.Second)
+	assert.NoError(t, err)
+	released, err = store.ReleaseLease(ctx, "queue1", message)
+	assert.NoError(t, err)
+	assert.True(t, released)
+
+	leases, err = store.ListLeasedMessages(ctx, "queue1")
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+
+	// A zero timeout waits until a message is enqueued
+	go func() {
+		time.Sleep(1100 * time.Millisecond)
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue2", "d"))
+	}()
+
+	start = time.Now()
+	message, err = store.LeaseMessage(ctx, "queue2", 100, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "d", message)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// Messages enqueued while waiting are leased before the timeout expires
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, store.EnqueueMessageNow(ctx, "queue2", "e"))
+	}()
+
+	start = time.Now()
+	message, err = store.LeaseMessage(ctx, "queue2", 100, 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "e", message)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,41 @@
+package storage_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pioneerworks/go-sidekiq/storage"
+	"github.com/pioneerworks/go-sidekiq/storage/storagetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRedisStore(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 15})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	storagetest.Run(t, func(t *testing.T, namespace string) storage.Store {
+		return storage.NewRedisStore(namespace, client, logger)
+	})
+}
+
+func TestBoltStore(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sidekiq.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	storagetest.Run(t, func(t *testing.T, namespace string) storage.Store {
+		store, err := storage.NewBoltStore(db, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
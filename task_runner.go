@@ -2,7 +2,7 @@ package workers
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -12,7 +12,7 @@ type taskRunner struct {
 	handler    JobFunc
 	currentMsg *Msg
 	lock       sync.RWMutex
-	logger     *log.Logger
+	logger     *slog.Logger
 }
 
 func (w *taskRunner) quit() {
@@ -21,6 +21,13 @@ func (w *taskRunner) quit() {
 
 func (w *taskRunner) work(messages <-chan *Msg, done chan<- *Msg, ready chan<- bool) {
 	for {
+		// Don't signal that we're ready once we were asked to quit
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
 		select {
 		case msg := <-messages:
 			msg.startedAt = time.Now().UTC().Unix()
@@ -30,7 +37,7 @@ func (w *taskRunner) work(messages <-chan *Msg, done chan<- *Msg, ready chan<- b
 			w.lock.Unlock()
 
 			if err := w.process(msg); err != nil {
-				w.logger.Println("ERR:", err)
+				w.logger.Error("couldn't process message", LogKeyClass, msg.Class(), LogKeyJid, msg.Jid(), LogKeyError, err)
 			}
 
 			w.lock.Lock()
@@ -67,7 +74,7 @@ func (w *taskRunner) inProgressMessage() *Msg {
 	return w.currentMsg
 }
 
-func newTaskRunner(logger *log.Logger, handler JobFunc) *taskRunner {
+func newTaskRunner(logger *slog.Logger, handler JobFunc) *taskRunner {
 	return &taskRunner{
 		handler: handler,
 		stop:    make(chan bool),
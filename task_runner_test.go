@@ -2,8 +2,7 @@ package workers
 
 import (
 	"errors"
-	"log"
-	"os"
+	"log/slog"
 	"sync"
 	"testing"
 
@@ -11,7 +10,7 @@ import (
 )
 
 func TestTaskRunner_process(t *testing.T) {
-	testLogger := log.New(os.Stdout, "test-go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+	testLogger := NewLogger(slog.LevelDebug, false)
 
 	msg, _ := NewMsg(`{}`)
 
@@ -63,8 +62,8 @@ func TestTaskRunner(t *testing.T) {
 
 	var wg sync.WaitGroup
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		tr.work(msgCh, doneCh, readyCh)
 		wg.Done()
 	}()
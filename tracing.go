@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	traceParentKey = "traceparent"
+	traceStateKey  = "tracestate"
+)
+
+// Tracer propagates trace context through job payloads and starts spans
+// around job execution. Implementations can wrap OpenTelemetry or record
+// spans for tests.
+type Tracer interface {
+	// Inject writes the trace context of ctx into the carrier using the
+	// W3C "traceparent" and "tracestate" keys
+	Inject(ctx context.Context, carrier map[string]string)
+	// Extract returns a copy of ctx carrying the trace context found in the carrier
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+	// StartConsumerSpan starts a consumer span as a child of the span in ctx
+	StartConsumerSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// TracingEnqueueHook returns an enqueue hook that injects the trace context of
+// the enqueuing context into the job payload
+func TracingEnqueueHook(tracer Tracer) EnqueueHookFunc {
+	return func(ctx context.Context, data *EnqueueData) error {
+		carrier := map[string]string{}
+		tracer.Inject(ctx, carrier)
+
+		data.TraceParent = carrier[traceParentKey]
+		data.TraceState = carrier[traceStateKey]
+		return nil
+	}
+}
+
+// TracingMiddleware returns a middleware that continues the trace found in the
+// job payload, and wraps the job in a consumer span. The span's context is
+// available to the job through Msg.Context.
+func TracingMiddleware(tracer Tracer) MiddlewareFunc {
+	return func(queue string, mgr *Manager, next JobFunc) JobFunc {
+		return func(message *Msg) (err error) {
+			carrier := map[string]string{}
+			if traceParent, e := message.Get(traceParentKey).String(); e == nil {
+				carrier[traceParentKey] = traceParent
+			}
+			if traceState, e := message.Get(traceStateKey).String(); e == nil {
+				carrier[traceStateKey] = traceState
+			}
+
+			ctx := tracer.Extract(message.Context(), carrier)
+			ctx, span := tracer.StartConsumerSpan(ctx, queue+" process", map[string]interface{}{
+				"messaging.system":           "sidekiq",
+				"messaging.destination.name": queue,
+				"messaging.message.id":       message.Jid(),
+				"sidekiq.job.class":          message.Class(),
+				"sidekiq.job.retry_count":    retryCount(message),
+			})
+
+			message.ctx = ctx
+			defer func() {
+				if e := recover(); e != nil {
+					var ok bool
+					if err, ok = e.(error); !ok {
+						err = fmt.Errorf("%v", e)
+					}
+					span.RecordError(err)
+					span.End()
+					panic(e)
+				}
+			}()
+
+			err = next(message)
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type traceContextKey struct{}
+
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]interface{}
+	errors     []error
+	ended      bool
+}
+
+func (s *recordedSpan) RecordError(err error) { s.errors = append(s.errors, err) }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+// recordingTracer keeps the traceparent in the context and records started spans
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Inject(ctx context.Context, carrier map[string]string) {
+	if traceParent, ok := ctx.Value(traceContextKey{}).(string); ok {
+		carrier["traceparent"] = traceParent
+		carrier["tracestate"] = "vendor=1"
+	}
+}
+
+func (r *recordingTracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if traceParent, ok := carrier["traceparent"]; ok {
+		return context.WithValue(ctx, traceContextKey{}, traceParent)
+	}
+	return ctx
+}
+
+func (r *recordingTracer) StartConsumerSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span) {
+	parent, _ := ctx.Value(traceContextKey{}).(string)
+	span := &recordedSpan{name: name, parent: parent, attributes: attributes}
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, traceContextKey{}, "child-of-"+parent), span
+}
+
+func TestTracingEnqueueHook(t *testing.T) {
+	ctx := context.Background()
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	tracer := &recordingTracer{}
+	opts.EnqueueHooks = []EnqueueHookFunc{TracingEnqueueHook(tracer)}
+	p := &Producer{opts: opts}
+
+	traceParent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	_, err = p.EnqueueContext(context.WithValue(ctx, traceContextKey{}, traceParent), "queue1", "Add", []int{1})
+	assert.NoError(t, err)
+	_, err = p.Enqueue("queue1", "Add", []int{2})
+	assert.NoError(t, err)
+
+	raw, _ := opts.client.LRange(ctx, "prod:queue:queue1", 0, -1).Result()
+	assert.Len(t, raw, 2)
+
+	untraced, _ := NewMsg(raw[0])
+	_, found := untraced.CheckGet("traceparent")
+	assert.False(t, found)
+
+	traced, _ := NewMsg(raw[1])
+	assert.Equal(t, traceParent, traced.Get("traceparent").MustString())
+	assert.Equal(t, "vendor=1", traced.Get("tracestate").MustString())
+}
+
+func TestEnqueueHookError(t *testing.T) {
+	opts, err := setupTestOptionsWithNamespace("prod")
+	assert.NoError(t, err)
+
+	opts.EnqueueHooks = []EnqueueHookFunc{func(ctx context.Context, data *EnqueueData) error {
+		return errors.New("rejected")
+	}}
+	p := &Producer{opts: opts}
+
+	jid, err := p.Enqueue("queue1", "Add", []int{1})
+	assert.EqualError(t, err, "rejected")
+	assert.Empty(t, jid)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	tracer := &recordingTracer{}
+	mids := NewMiddlewares(TracingMiddleware(tracer))
+
+	message, _ := NewMsg(`{"jid":"2","class":"Add","retry_count":3,"traceparent":"parent"}`)
+	var jobCtx context.Context
+	err := mids.build("prod:myqueue", nil, func(m *Msg) error {
+		jobCtx = m.Context()
+		return errors.New("AHHHH")
+	})(message)
+	assert.EqualError(t, err, "AHHHH")
+
+	assert.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, "prod:myqueue process", span.name)
+	assert.Equal(t, "parent", span.parent)
+	assert.True(t, span.ended)
+	assert.Equal(t, []error{errors.New("AHHHH")}, span.errors)
+	assert.Equal(t, map[string]interface{}{
+		"messaging.system":           "sidekiq",
+		"messaging.destination.name": "prod:myqueue",
+		"messaging.message.id":       "2",
+		"sidekiq.job.class":          "Add",
+		"sidekiq.job.retry_count":    3,
+	}, span.attributes)
+	assert.Equal(t, "child-of-parent", jobCtx.Value(traceContextKey{}))
+
+	// panics are recorded and propagated to the outer middlewares
+	message, _ = NewMsg(`{"jid":"3","class":"Add"}`)
+	assert.Panics(t, func() {
+		mids.build("prod:myqueue", nil, panickingFunc)(message)
+	})
+	assert.Len(t, tracer.spans, 2)
+	assert.Equal(t, "", tracer.spans[1].parent)
+	assert.True(t, tracer.spans[1].ended)
+	assert.EqualError(t, tracer.spans[1].errors[0], errorText)
+}
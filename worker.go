@@ -1,22 +1,43 @@
 package workers
 
 import (
-	"log"
+	"log/slog"
 	"sync"
 )
 
 type worker struct {
 	queue       string
+	newFetcher  FetcherFactory
+	policy      ConcurrencyPolicy
 	handler     JobFunc
 	concurrency int
 	runners     []*taskRunner
 	runnersLock sync.Mutex
 	stop        chan bool
+	quietCh     chan bool
 	running     bool
-	logger      *log.Logger
+	logger      *slog.Logger
+
+	// Set when the worker is asked to quit before it started, so that it doesn't start
+	quitting bool
+
+	// Set when the worker stopped fetching new jobs until it quits
+	quieted bool
+
+	// Set when the manager started the worker in its current run, and closed once it stopped
+	stopped chan bool
+
+	// Set while the worker runs, so that runners can be added and drained
+	fetcher  Fetcher
+	done     chan *Msg
+	wg       *sync.WaitGroup
+	stopping bool
+
+	// Runners that were quit when the concurrency was lowered, until their job is done
+	draining map[*taskRunner]bool
 }
 
-func newWorker(logger *log.Logger, queue string, concurrency int, handler JobFunc) *worker {
+func newWorker(logger *slog.Logger, queue string, concurrency int, handler JobFunc) *worker {
 	if concurrency <= 0 {
 		concurrency = 1
 	}
@@ -25,7 +46,9 @@ func newWorker(logger *log.Logger, queue string, concurrency int, handler JobFun
 		handler:     handler,
 		concurrency: concurrency,
 		stop:        make(chan bool),
+		quietCh:     make(chan bool),
 		logger:      logger,
+		draining:    map[*taskRunner]bool{},
 	}
 	return w
 }
@@ -36,27 +59,31 @@ func (w *worker) start(fetcher Fetcher) {
 		w.runnersLock.Unlock()
 		return
 	}
+	if w.quitting {
+		w.quitting = false
+		w.runnersLock.Unlock()
+		return
+	}
 	w.running = true
 	defer func() {
 		w.runnersLock.Lock()
 		w.running = false
+		w.quieted = false
+		w.fetcher = nil
 		w.runnersLock.Unlock()
 	}()
 
 	var wg sync.WaitGroup
-	wg.Add(w.concurrency)
+	w.wg = &wg
+	w.fetcher = fetcher
+	w.done = make(chan *Msg)
+	w.stopping = false
 
 	go fetcher.Fetch()
 
-	done := make(chan *Msg)
-	w.runners = make([]*taskRunner, w.concurrency)
+	w.runners = nil
 	for i := 0; i < w.concurrency; i++ {
-		r := newTaskRunner(w.logger, w.handler)
-		w.runners[i] = r
-		go func() {
-			r.work(fetcher.Messages(), done, fetcher.Ready())
-			wg.Done()
-		}()
+		w.startRunner()
 	}
 	exit := make(chan bool)
 	go func() {
@@ -64,37 +91,113 @@ func (w *worker) start(fetcher Fetcher) {
 		close(exit)
 	}()
 
+	// The worker was quieted before it started
+	quieted := w.quieted
+
 	// Now that we're all set up, unlock so that stats can check.
 	w.runnersLock.Unlock()
 
+	if quieted {
+		fetcher.Close()
+	}
+
 	for {
 		select {
-		case msg := <-done:
+		case msg := <-w.done:
 			if msg.ack {
 				fetcher.Acknowledge(msg)
+			} else if a, ok := fetcher.(abandoner); ok {
+				a.Abandon(msg)
+			}
+		case <-w.quietCh:
+			if !fetcher.Closed() {
+				fetcher.Close()
 			}
 		case <-w.stop:
 			if !fetcher.Closed() {
 				fetcher.Close()
+			}
 
-				// we need to relock the runners so we can shut this down
-				w.runnersLock.Lock()
+			// we need to relock the runners so we can shut this down
+			w.runnersLock.Lock()
+			if !w.stopping {
+				w.stopping = true
 				for _, r := range w.runners {
 					r.quit()
 				}
-				w.runnersLock.Unlock()
 			}
+			w.runnersLock.Unlock()
 		case <-exit:
 			return
 		}
 	}
 }
 
+// startRunner starts a runner for the running worker. The runners lock must be held.
+func (w *worker) startRunner() {
+	r := newTaskRunner(w.logger, w.handler)
+	w.runners = append(w.runners, r)
+	w.wg.Add(1)
+
+	fetcher, done, wg := w.fetcher, w.done, w.wg
+	go func() {
+		r.work(fetcher.Messages(), done, fetcher.Ready())
+
+		w.runnersLock.Lock()
+		delete(w.draining, r)
+		w.runnersLock.Unlock()
+		wg.Done()
+	}()
+}
+
+// setConcurrency changes the number of runners. When the worker runs, runners are
+// started right away, or quit once they finished the job they are processing.
+func (w *worker) setConcurrency(concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	w.concurrency = concurrency
+	if !w.running || w.stopping {
+		return
+	}
+
+	for len(w.runners) < concurrency {
+		w.startRunner()
+	}
+	for len(w.runners) > concurrency {
+		r := w.runners[len(w.runners)-1]
+		w.runners = w.runners[:len(w.runners)-1]
+		w.draining[r] = true
+		r.quit()
+	}
+}
+
+func (w *worker) getConcurrency() int {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	return w.concurrency
+}
+
 func (w *worker) quit() {
 	w.runnersLock.Lock()
 	defer w.runnersLock.Unlock()
 	if w.running {
 		w.stop <- true
+	} else {
+		w.quitting = true
+	}
+}
+
+// quiet stops fetching new jobs, while the runners finish the jobs they are processing
+func (w *worker) quiet() {
+	w.runnersLock.Lock()
+	defer w.runnersLock.Unlock()
+	w.quieted = true
+	if w.running {
+		w.quietCh <- true
 	}
 }
 
@@ -107,5 +210,10 @@ func (w *worker) inProgressMessages() []*Msg {
 			res = append(res, m)
 		}
 	}
+	for r := range w.draining {
+		if m := r.inProgressMessage(); m != nil {
+			res = append(res, m)
+		}
+	}
 	return res
 }
@@ -1,8 +1,7 @@
 package workers
 
 import (
-	"log"
-	"os"
+	"log/slog"
 	"sync"
 	"testing"
 
@@ -28,7 +27,7 @@ func (d dummyFetcher) Close()              { d.close() }
 func (d dummyFetcher) Closed() bool        { return d.closed() }
 
 func TestNewWorker(t *testing.T) {
-	testLogger := log.New(os.Stdout, "test-go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+	testLogger := NewLogger(slog.LevelDebug, false)
 
 	cc := newCallCounter()
 	w := newWorker(testLogger, "q", 0, cc.F)
@@ -48,7 +47,7 @@ func TestNewWorker(t *testing.T) {
 }
 
 func TestWorker(t *testing.T) {
-	testLogger := log.New(os.Stdout, "test-go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+	testLogger := NewLogger(slog.LevelDebug, false)
 
 	readyCh := make(chan bool)
 	msgCh := make(chan *Msg)
@@ -80,8 +79,8 @@ func TestWorker(t *testing.T) {
 	w := newWorker(testLogger, "q", 2, cc.F)
 
 	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		w.start(df)
 		wg.Done()
 	}()
@@ -143,7 +142,7 @@ func TestWorker(t *testing.T) {
 }
 
 func TestWorkerProcessesAndAcksMessages(t *testing.T) {
-	testLogger := log.New(os.Stdout, "test-go-sidekiq: ", log.Ldate|log.Lmicroseconds)
+	testLogger := NewLogger(slog.LevelDebug, false)
 
 	readyCh := make(chan bool)
 	msgCh := make(chan *Msg)
@@ -172,8 +171,8 @@ func TestWorkerProcessesAndAcksMessages(t *testing.T) {
 
 	var wg sync.WaitGroup
 
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		w.start(df)
 		wg.Done()
 	}()